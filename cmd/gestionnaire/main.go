@@ -8,18 +8,29 @@ import (
 	"gestionnaire-telechargement/internal/ui"
 	"log"
 	"os"
+	"path/filepath"
 
 	"golang.org/x/text/language"
 )
 
+// Variable d'environnement indiquant l'emplacement de la base de données
+const dbPathEnv = "GOLOAD_DB"
+
 func main() {
 	lang := flag.String("lang", "en", "Set the default language (en or fr)")
+	dbPath := flag.String("db", "", "Database file (default: $"+dbPathEnv+", else $XDG_DATA_HOME/goload/"+database.FileName+")")
+	portable := flag.Bool("portable", false, "Keep the database and downloads next to the executable")
 	flag.Parse()
 
 	fmt.Println("Starting download manager")
 
 	// Initialiser la base de données
-	db, err := database.NewDatabase()
+	path, err := databasePath(*dbPath, *portable)
+	if err != nil {
+		log.Fatalf("Error locating database: %v", err)
+	}
+	log.Printf("Base de données : %s", path)
+	db, err := database.NewDatabase(path)
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
@@ -30,60 +41,151 @@ func main() {
 
 	// Initialiser le downloader
 	d := downloader.NewDownloader(maxChunks)
+	if *portable {
+		// Le dossier choisi dans les paramètres reste prioritaire
+		d.DownloadDir = filepath.Join(filepath.Dir(path), "Downloads")
+	}
 
-	d.OnDownloadAdded = func(url string, totalSize int64) error {
-		if err := db.AddDownload(url, totalSize); err != nil {
-			return fmt.Errorf("impossible d'ajouter le téléchargement à la base de données : %v", err)
-		}
-		return nil
+	d.Store = db
+
+	// La base de données et le journal suivent les téléchargements chacun de leur côté
+	persisted := make(chan struct{})
+	go func() {
+		defer close(persisted)
+		persistEvents(db, d.Subscribe(downloader.DefaultEventBuffer))
+	}()
+	go logEvents(d.Subscribe(downloader.DefaultEventBuffer))
+
+	// Set the default language
+	switch *lang {
+	case "fr":
+		ui.SetLanguage(language.French)
+	default:
+		ui.SetLanguage(language.English)
 	}
 
-	d.OnComplete = func(url string) error {
-		if err := db.UpdateDownloadStatus(url, "completed"); err != nil {
-			return fmt.Errorf("impossible de mettre à jour le statut du téléchargement : %v", err)
+	// Initialiser l'interface utilisateur
+	u := ui.NewUI(d, db)
+
+	// Démarrer l'interface
+	u.Start()
+
+	// Interrompre les téléchargements en cours en conservant leur avancement
+	d.Shutdown()
+	// Enregistrer les derniers événements avant de fermer la base de données
+	<-persisted
+}
+
+// databasePath choisit l'emplacement de la base de données : option -db, puis
+// variable GOLOAD_DB, puis dossier de l'exécutable en mode portable, sinon
+// dossier de données XDG. Dans ces deux derniers cas, la base que les versions
+// précédentes créaient dans le dossier courant y est déplacée.
+func databasePath(flagPath string, portable bool) (string, error) {
+	if flagPath != "" {
+		return flagPath, nil
+	}
+	if envPath := os.Getenv(dbPathEnv); envPath != "" {
+		return envPath, nil
+	}
+
+	var path string
+	if portable {
+		dir, err := database.PortableDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, database.FileName)
+	} else {
+		var err error
+		if path, err = database.DefaultPath(); err != nil {
+			return "", err
 		}
-		return nil
 	}
 
-	d.OnPause = func(url string) error {
-		return db.UpdateDownloadStatus(url, "paused")
+	legacy, err := database.MoveLegacyDatabase(path)
+	if err != nil {
+		return "", fmt.Errorf("impossible de déplacer %s vers %s : %v", database.FileName, path, err)
 	}
+	if legacy != "" {
+		log.Printf("Base de données déplacée de %s vers %s", legacy, path)
+	}
+	return path, nil
+}
 
-	d.OnResume = func(url string) error {
-		return db.UpdateDownloadStatus(url, "downloading")
+// persistEvents enregistre en base de données le statut des téléchargements
+func persistEvents(db *database.Database, sub *downloader.Subscription) {
+	for event := range sub.Events() {
+		if err := persistEvent(db, event); err != nil {
+			log.Printf("Erreur lors de l'enregistrement de l'événement « %v » : %v", event, err)
+		}
 	}
+}
 
-	d.OnDeleted = func(url string, deleteFile bool) error {
-		db.UpdateDownloadStatus(url, "deleted")
-		if deleteFile {
-			details, err := db.GetDownloadDetails(url)
+// persistEvent applique un événement à la base de données : le changement de
+// statut, refusé s'il est interdit, puis ce qui en découle
+func persistEvent(db *database.Database, event downloader.Event) error {
+	switch event.Type {
+	case downloader.EventDeleted:
+		if event.DeleteFile {
+			details, err := db.GetDownloadDetails(event.ID)
 			if err == nil && details.SavePath != "" {
 				os.Remove(details.SavePath)
 			}
 		}
-
-		return db.DeleteDownload(url)
+		// Sans sa ligne en base, un fichier temporaire ne peut plus être repris
+		removePartial(db, event.ID)
+		return db.DeleteDownload(event.ID, eventCause(event))
+	case downloader.EventQueueChanged:
+		return db.SetQueueOrder(event.IDs)
 	}
 
-	d.OnCancel = func(url string) error {
-		return db.UpdateDownloadStatus(url, "cancelled")
+	status, ok := event.Status()
+	if !ok {
+		return nil
+	}
+	if err := db.UpdateDownloadStatus(event.ID, status, eventCause(event)); err != nil {
+		return err
 	}
 
-	d.OnError = func(url string, err error) {
-		db.UpdateDownloadStatus(url, "failed")
+	switch event.Type {
+	case downloader.EventStarted:
+		return db.SetDownloadStarted(event.ID, event.Time)
+	case downloader.EventCompleted:
+		return db.SetDownloadFinished(event.ID, event.Time, "")
+	case downloader.EventFailed:
+		return db.SetDownloadFinished(event.ID, event.Time, event.Err.Error())
+	case downloader.EventCancelled:
+		// Un téléchargement annulé ne sera pas repris : ses fichiers temporaires sont inutiles
+		removePartial(db, event.ID)
+		return db.SetDownloadFinished(event.ID, event.Time, "")
 	}
+	return nil
+}
 
-	// Set the default language
-	switch *lang {
-	case "fr":
-		ui.SetLanguage(language.French)
-	default:
-		ui.SetLanguage(language.English)
+// eventCause décrit, pour l'historique des statuts, l'événement qui a changé le statut
+func eventCause(event downloader.Event) string {
+	if event.Err != nil {
+		return fmt.Sprintf("%v : %v", event.Type, event.Err)
 	}
+	return event.Type.String()
+}
 
-	// Initialiser l'interface utilisateur
-	u := ui.NewUI(d, db)
+// removePartial supprime les fichiers temporaires d'un téléchargement
+func removePartial(db *database.Database, id int64) {
+	details, err := db.GetDownloadDetails(id)
+	if err != nil || details.SavePath == "" {
+		return
+	}
+	if err := downloader.RemovePartial(details.SavePath); err != nil {
+		log.Printf("Erreur lors de la suppression des fichiers temporaires : %v", err)
+	}
+}
 
-	// Démarrer l'interface
-	u.Start()
+// logEvents journalise le cycle de vie des téléchargements, sans leur progression
+func logEvents(sub *downloader.Subscription) {
+	for event := range sub.Events() {
+		if event.Type != downloader.EventProgress {
+			log.Print(event)
+		}
+	}
 }
@@ -5,6 +5,12 @@ import (
 	"fmt"
 	"gestionnaire-telechargement/internal/downloader"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/glebarez/go-sqlite"
 )
@@ -14,10 +20,11 @@ type Database struct {
 }
 
 type Download struct {
-	ID     int64
-	URL    string
-	Status string
-	Size   int64 // Ajoutez cette ligne
+	ID       int64
+	URL      string
+	Status   downloader.Status
+	Size     int64 // Ajoutez cette ligne
+	FileName string
 }
 
 type Setting struct {
@@ -25,71 +32,190 @@ type Setting struct {
 	Value string
 }
 
-func NewDatabase() (*Database, error) {
+// NewDatabase ouvre la base de données située à path, créée avec son dossier si
+// elle n'existe pas, et met son schéma à jour
+func NewDatabase(path string) (*Database, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("impossible de créer le dossier de la base de données : %v", err)
+	}
 
-	db, err := sql.Open("sqlite", "goloader.db")
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, fmt.Errorf("impossible d'ouvrir la base de données : %v", err)
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("impossible de se connecter à la base de données : %v", err)
 	}
 
 	database := &Database{db: db}
-	if err := database.createDownloadTable(); err != nil {
-		return nil, fmt.Errorf("impossible de créer la table downloads : %v", err)
+	if err := database.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("impossible de migrer la base de données : %w", err)
 	}
 
-	// Ajoutez cette ligne pour créer la table settings
-	if err := database.createSettingsTable(); err != nil {
-		return nil, fmt.Errorf("impossible de créer la table settings : %v", err)
+	if err := database.insertDefaultSettings(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("impossible d'enregistrer les paramètres par défaut : %v", err)
 	}
 
-	// Appelez la méthode migrate pour mettre à jour la table existante
-	if err := database.migrate(); err != nil {
-		return nil, fmt.Errorf("impossible de migrer la table : %v", err)
+	return database, nil
+}
+
+// insertDefaultSettings enregistre les valeurs par défaut des paramètres absents,
+// sans écraser celles choisies par l'utilisateur
+func (d *Database) insertDefaultSettings() error {
+	hostLimit := downloader.DefaultHostLimit()
+	defaults := map[string]string{
+		"host_max_downloads":   strconv.Itoa(hostLimit.MaxDownloads),
+		"host_max_connections": strconv.Itoa(hostLimit.MaxConnections),
+		"host_limit_overrides": "",
+		"min_free_space":       strconv.Itoa(downloader.DefaultMinFreeSpace),
+		"proxy_url":            "",
+		"proxy_rules":          "",
+		"tls_ca_files":         "",
+		"tls_client_certs":     "",
+		"tls_pins":             "",
+		"tls_insecure_hosts":   "",
 	}
 
-	return database, nil
+	for key, value := range defaults {
+		if _, err := d.db.Exec("INSERT OR IGNORE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (d *Database) createDownloadTable() error {
-	query := `CREATE TABLE IF NOT EXISTS downloads (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		url TEXT NOT NULL,
-		status TEXT NOT NULL,
-		size INTEGER NOT NULL DEFAULT 0
-	)`
+// StatusTransition est un changement de statut consigné dans l'historique
+type StatusTransition struct {
+	From  downloader.Status // Vide à l'ajout du téléchargement
+	To    downloader.Status
+	Cause string
+	Time  time.Time
+}
 
-	_, err := d.db.Exec(query)
-	return err
+// AddDownload enregistre un nouveau téléchargement et retourne son ID
+func (d *Database) AddDownload(url string, size int64) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Un nouveau téléchargement est placé en fin de file
+	query := "INSERT INTO downloads (url, status, size, created_at, queue_position) VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(queue_position), 0) + 1 FROM downloads))"
+	result, err := tx.Exec(query, url, downloader.StatusPending, size, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := recordTransition(tx, id, "", downloader.StatusPending, "ajouté"); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-func (d *Database) createSettingsTable() error {
-	query := `CREATE TABLE IF NOT EXISTS settings (
-		key TEXT PRIMARY KEY,
-		value TEXT
-	)`
+// GetDownloadStatus retourne le statut enregistré, vide si le téléchargement est inconnu
+func (d *Database) GetDownloadStatus(id int64) (downloader.Status, error) {
+	var status downloader.Status
+	err := d.db.QueryRow("SELECT status FROM downloads WHERE id = ?", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return status, err
+}
+
+// UpdateDownloadStatus fait passer le téléchargement au statut donné si la table
+// des transitions du downloader le permet, et consigne le changement avec sa
+// cause ; un changement interdit est refusé avec downloader.ErrInvalidTransition
+func (d *Database) UpdateDownloadStatus(id int64, status downloader.Status, cause string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setStatus(tx, id, status, cause); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	_, err := d.db.Exec(query)
+// setStatus vérifie puis applique un changement de statut dans la transaction
+func setStatus(tx *sql.Tx, id int64, status downloader.Status, cause string) error {
+	var current downloader.Status
+	err := tx.QueryRow("SELECT status FROM downloads WHERE id = ?", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("aucun téléchargement trouvé pour l'ID : %d", id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := downloader.CheckTransition(current, status); err != nil {
+		return fmt.Errorf("téléchargement %d : %w", id, err)
+	}
+	if current == status {
+		return nil
+	}
+
+	if _, err := tx.Exec("UPDATE downloads SET status = ? WHERE id = ?", status, id); err != nil {
+		return err
+	}
+	return recordTransition(tx, id, current, status, cause)
+}
+
+func recordTransition(tx *sql.Tx, id int64, from, to downloader.Status, cause string) error {
+	query := "INSERT INTO status_transitions (download_id, from_status, to_status, cause, at) VALUES (?, ?, ?, ?, ?)"
+	_, err := tx.Exec(query, id, from, to, cause, time.Now().Unix())
 	return err
 }
 
-func (d *Database) AddDownload(url string, size int64) error {
-	query := "INSERT INTO downloads (url, status, size) VALUES (?, ?, ?)"
-	_, err := d.db.Exec(query, url, "pending", size)
+// GetStatusTransitions retourne l'historique des statuts d'un téléchargement,
+// du plus ancien au plus récent
+func (d *Database) GetStatusTransitions(id int64) ([]StatusTransition, error) {
+	rows, err := d.db.Query("SELECT from_status, to_status, cause, at FROM status_transitions WHERE download_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []StatusTransition
+	for rows.Next() {
+		var transition StatusTransition
+		var at int64
+		if err := rows.Scan(&transition.From, &transition.To, &transition.Cause, &at); err != nil {
+			return nil, err
+		}
+		transition.Time = fromUnix(at)
+		transitions = append(transitions, transition)
+	}
+	return transitions, rows.Err()
+}
+
+// SetDownloadStarted enregistre le début du transfert : la date du premier
+// démarrage est conservée, celle de fin et la dernière erreur sont effacées
+func (d *Database) SetDownloadStarted(id int64, at time.Time) error {
+	query := "UPDATE downloads SET started_at = CASE WHEN started_at = 0 THEN ? ELSE started_at END, finished_at = 0, last_error = '' WHERE id = ?"
+	_, err := d.db.Exec(query, at.Unix(), id)
 	return err
 }
 
-func (d *Database) UpdateDownloadStatus(url, status string) error {
-	query := "UPDATE downloads SET status = ? WHERE url = ?"
-	_, err := d.db.Exec(query, status, url)
+// SetDownloadFinished enregistre la fin du transfert et, s'il a échoué, sa cause
+func (d *Database) SetDownloadFinished(id int64, at time.Time, lastError string) error {
+	_, err := d.db.Exec("UPDATE downloads SET finished_at = ?, last_error = ? WHERE id = ?", at.Unix(), lastError, id)
 	return err
 }
 
 func (d *Database) GetPendingDownloads() ([]Download, error) {
-	query := "SELECT id, url, status, size FROM downloads WHERE status = 'pending'"
+	// Les téléchargements restés "downloading" ont été interrompus par un arrêt du programme
+	query := "SELECT id, url, status, size, file_name FROM downloads WHERE status IN ('pending', 'downloading') ORDER BY priority DESC, queue_position, id"
 	rows, err := d.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -99,7 +225,7 @@ func (d *Database) GetPendingDownloads() ([]Download, error) {
 	var downloads []Download
 	for rows.Next() {
 		var d Download
-		if err := rows.Scan(&d.ID, &d.URL, &d.Status, &d.Size); err != nil {
+		if err := rows.Scan(&d.ID, &d.URL, &d.Status, &d.Size, &d.FileName); err != nil {
 			return nil, err
 		}
 		downloads = append(downloads, d)
@@ -117,7 +243,7 @@ func (d *Database) Close() {
 // Ajoutez ces nouvelles méthodes
 
 func (d *Database) GetAllDownloads() ([]Download, error) {
-	query := "SELECT id, url, status, size FROM downloads"
+	query := "SELECT id, url, status, size, file_name FROM downloads ORDER BY queue_position, id"
 	rows, err := d.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -127,7 +253,7 @@ func (d *Database) GetAllDownloads() ([]Download, error) {
 	var downloads []Download
 	for rows.Next() {
 		var d Download
-		if err := rows.Scan(&d.ID, &d.URL, &d.Status, &d.Size); err != nil {
+		if err := rows.Scan(&d.ID, &d.URL, &d.Status, &d.Size, &d.FileName); err != nil {
 			return nil, err
 		}
 		downloads = append(downloads, d)
@@ -136,76 +262,261 @@ func (d *Database) GetAllDownloads() ([]Download, error) {
 	return downloads, nil
 }
 
-func (d *Database) DeleteDownload(url string) error {
-	query := "DELETE FROM downloads WHERE url = ?"
-	_, err := d.db.Exec(query, url)
-	return err
+// DeleteDownload retire le téléchargement de la base ; sa suppression reste
+// consignée dans l'historique des statuts
+func (d *Database) DeleteDownload(id int64, cause string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setStatus(tx, id, downloader.StatusDeleted, cause); err != nil {
+		return err
+	}
+
+	query := "DELETE FROM download_chunks WHERE download_id = ?"
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+
+	query = "DELETE FROM download_credentials WHERE download_id = ?"
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+
+	query = "DELETE FROM downloads WHERE id = ?"
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (d *Database) GetDownloadByURL(url string) (Download, error) {
-	query := "SELECT id, url, status, size FROM downloads WHERE url = ?"
-	row := d.db.QueryRow(query, url)
+func (db *Database) GetDownloadDetails(id int64) (*downloader.Download, error) {
+	query := "SELECT id, url, status, size, downloaded, file_name, file_path, retry_count, speed_limit, priority, etag, last_modified, created_at, started_at, finished_at, last_error FROM downloads WHERE id = ?"
+	row := db.db.QueryRow(query, id)
+
+	var download downloader.Download
+	var createdAt, startedAt, finishedAt int64
+	err := row.Scan(&download.ID, &download.URL, &download.Status, &download.Size, &download.DownloadedSize, &download.FileName, &download.SavePath, &download.Retries, &download.SpeedLimit, &download.Priority, &download.ETag, &download.LastModified, &createdAt, &startedAt, &finishedAt, &download.LastError)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("aucun téléchargement trouvé pour l'ID : %d", id)
+		}
+		return nil, fmt.Errorf("erreur lors de la récupération des détails du téléchargement : %v", err)
+	}
+	download.CreatedAt = fromUnix(createdAt)
+	download.StartedAt = fromUnix(startedAt)
+	download.FinishedAt = fromUnix(finishedAt)
 
-	var download Download
-	err := row.Scan(&download.ID, &download.URL, &download.Status, &download.Size)
+	download.Chunks, err = db.getChunks(download.ID)
 	if err != nil {
-		return Download{}, err
+		return nil, fmt.Errorf("erreur lors de la récupération des chunks : %v", err)
 	}
 
-	return download, nil
+	return &download, nil
 }
 
-func (d *Database) migrate() error {
-	// Vérifiez si la colonne 'size' existe
-	query := "PRAGMA table_info(downloads)"
-	rows, err := d.db.Query(query)
+// SaveDownloadState enregistre l'avancement d'un téléchargement pour permettre sa reprise
+func (d *Database) SaveDownloadState(id int64, state downloader.ResumeState) error {
+	tx, err := d.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	var downloaded int64
+	for _, chunk := range state.Chunks {
+		downloaded += chunk.Downloaded
+	}
+
+	query := "UPDATE downloads SET size = ?, downloaded = ?, file_name = ?, file_path = ?, etag = ?, last_modified = ?, retry_count = ?, speed_limit = ?, checksum = ? WHERE id = ?"
+	if _, err := tx.Exec(query, state.Size, downloaded, state.FileName, state.FilePath, state.ETag, state.LastModified, state.Retries, state.SpeedLimit, state.Checksum, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM download_chunks WHERE download_id = ?", id); err != nil {
+		return err
+	}
+	for _, chunk := range state.Chunks {
+		query := "INSERT INTO download_chunks (download_id, chunk_id, start_offset, end_offset, size, downloaded) VALUES (?, ?, ?, ?, ?, ?)"
+		if _, err := tx.Exec(query, id, chunk.ID, chunk.Start, chunk.End, chunk.Size, chunk.Downloaded); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetDownloadState retourne l'avancement enregistré, ou nil si le téléchargement est inconnu
+func (d *Database) GetDownloadState(id int64) (*downloader.ResumeState, error) {
+	query := "SELECT url, size, file_name, file_path, etag, last_modified, retry_count, speed_limit, checksum, priority, request_headers, cookies, user_agent, referer FROM downloads WHERE id = ?"
+	row := d.db.QueryRow(query, id)
+
+	var state downloader.ResumeState
+	var headers, cookies string
+	err := row.Scan(&state.URL, &state.Size, &state.FileName, &state.FilePath, &state.ETag, &state.LastModified, &state.Retries, &state.SpeedLimit, &state.Checksum, &state.Priority, &headers, &cookies, &state.Options.UserAgent, &state.Options.Referer)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Options.Headers, err = downloader.ParseHeaders(headers); err != nil {
+		return nil, fmt.Errorf("en-têtes enregistrés invalides : %v", err)
+	}
+	if state.Options.Cookies, err = downloader.ParseCookies(cookies); err != nil {
+		return nil, fmt.Errorf("cookies enregistrés invalides : %v", err)
+	}
+
+	query = "SELECT username, password, token FROM download_credentials WHERE download_id = ?"
+	err = d.db.QueryRow(query, id).Scan(&state.Credentials.Username, &state.Credentials.Password, &state.Credentials.Token)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	state.Chunks, err = d.getChunks(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// SetDownloadSpeedLimit enregistre le débit maximal propre à un téléchargement
+func (d *Database) SetDownloadSpeedLimit(id int64, bytesPerSecond int64) error {
+	_, err := d.db.Exec("UPDATE downloads SET speed_limit = ? WHERE id = ?", bytesPerSecond, id)
+	return err
+}
+
+// SetDownloadChecksum enregistre l'empreinte attendue d'un téléchargement
+func (d *Database) SetDownloadChecksum(id int64, checksum string) error {
+	_, err := d.db.Exec("UPDATE downloads SET checksum = ? WHERE id = ?", checksum, id)
+	return err
+}
+
+// SetDownloadOptions enregistre les options HTTP d'un téléchargement, réutilisées
+// à chaque reprise et nouvelle tentative
+func (d *Database) SetDownloadOptions(id int64, options downloader.HTTPOptions) error {
+	query := "UPDATE downloads SET request_headers = ?, cookies = ?, user_agent = ?, referer = ? WHERE id = ?"
+	_, err := d.db.Exec(query, downloader.FormatHeaders(options.Headers), downloader.FormatCookies(options.Cookies), options.UserAgent, options.Referer, id)
+	return err
+}
+
+// SetDownloadCredentials enregistre les identifiants propres à un téléchargement ;
+// des identifiants vides suppriment ceux enregistrés
+func (d *Database) SetDownloadCredentials(id int64, creds downloader.Credentials) error {
+	if creds.IsZero() {
+		_, err := d.db.Exec("DELETE FROM download_credentials WHERE download_id = ?", id)
+		return err
+	}
+	query := "INSERT OR REPLACE INTO download_credentials (download_id, username, password, token) VALUES (?, ?, ?, ?)"
+	_, err := d.db.Exec(query, id, creds.Username, creds.Password, creds.Token)
+	return err
+}
+
+// GetHostCredentials retourne les identifiants enregistrés par hôte, triés par motif
+func (d *Database) GetHostCredentials() ([]downloader.HostCredentials, error) {
+	rows, err := d.db.Query("SELECT pattern, username, password, token FROM host_credentials ORDER BY pattern")
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var columnName string
+	var entries []downloader.HostCredentials
 	for rows.Next() {
-		var cid int
-		var name, ctype string
-		var notnull, pk int
-		var dfltValue sql.NullString // Utilisez sql.NullString pour gérer les valeurs NULL
-		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
-			return err
+		var entry downloader.HostCredentials
+		if err := rows.Scan(&entry.Pattern, &entry.Username, &entry.Password, &entry.Token); err != nil {
+			return nil, err
 		}
-		if name == "size" {
-			columnName = name
-			break
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// SaveHostCredentials enregistre ou remplace les identifiants d'un hôte
+func (d *Database) SaveHostCredentials(entry downloader.HostCredentials) error {
+	query := "INSERT OR REPLACE INTO host_credentials (pattern, username, password, token) VALUES (?, ?, ?, ?)"
+	_, err := d.db.Exec(query, strings.ToLower(entry.Pattern), entry.Username, entry.Password, entry.Token)
+	return err
+}
+
+// DeleteHostCredentials oublie les identifiants enregistrés pour un hôte
+func (d *Database) DeleteHostCredentials(pattern string) error {
+	_, err := d.db.Exec("DELETE FROM host_credentials WHERE pattern = ?", pattern)
+	return err
+}
+
+// SetDownloadPriority enregistre la priorité d'un téléchargement dans la file d'attente
+func (d *Database) SetDownloadPriority(id int64, priority downloader.Priority) error {
+	_, err := d.db.Exec("UPDATE downloads SET priority = ? WHERE id = ?", priority, id)
+	return err
+}
+
+// SetQueueOrder enregistre l'ordre des téléchargements en attente. Les positions
+// déjà occupées par ces téléchargements sont redistribuées dans le nouvel ordre,
+// sans déplacer les autres téléchargements.
+func (d *Database) SetQueueOrder(order []int64) error {
+	if len(order) == 0 {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	positions := make([]int64, 0, len(order))
+	for _, id := range order {
+		var position int64
+		err := tx.QueryRow("SELECT queue_position FROM downloads WHERE id = ?", id).Scan(&position)
+		if err != nil && err != sql.ErrNoRows {
+			return err
 		}
+		positions = append(positions, position)
 	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
 
-	// Si la colonne 'size' n'existe pas, ajoutez-la
-	if columnName != "size" {
-		_, err := d.db.Exec("ALTER TABLE downloads ADD COLUMN size INTEGER NOT NULL DEFAULT 0")
-		if err != nil {
+	for i, id := range order {
+		if _, err := tx.Exec("UPDATE downloads SET queue_position = ? WHERE id = ?", positions[i], id); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (db *Database) GetDownloadDetails(url string) (*downloader.Download, error) {
-	// Implémentez la logique pour récupérer les détails du téléchargement
-	// à partir de la base de données
-	query := "SELECT id, url, status, size FROM downloads WHERE url = ?"
-	row := db.db.QueryRow(query, url)
+// fromUnix convertit une date enregistrée, 0 correspondant à une date inconnue
+func fromUnix(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
 
-	var download downloader.Download
-	err := row.Scan(&download.ID, &download.URL, &download.Status, &download.Size)
+func (d *Database) getChunks(downloadID int64) ([]downloader.ChunkInfo, error) {
+	query := "SELECT chunk_id, start_offset, end_offset, size, downloaded FROM download_chunks WHERE download_id = ? ORDER BY chunk_id"
+	rows, err := d.db.Query(query, downloadID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("aucun téléchargement trouvé pour l'URL : %s", url)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []downloader.ChunkInfo
+	for rows.Next() {
+		var chunk downloader.ChunkInfo
+		if err := rows.Scan(&chunk.ID, &chunk.Start, &chunk.End, &chunk.Size, &chunk.Downloaded); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("erreur lors de la récupération des détails du téléchargement : %v", err)
+		if chunk.Size > 0 {
+			chunk.Progress = float64(chunk.Downloaded) / float64(chunk.Size)
+		}
+		chunks = append(chunks, chunk)
 	}
 
-	return &download, nil
+	return chunks, rows.Err()
 }
 
 func (d *Database) GetSetting(key string) (string, error) {
@@ -221,3 +532,19 @@ func (d *Database) SetSetting(key, value string) error {
 	_, err := d.db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
 	return err
 }
+
+// SetSettings enregistre plusieurs paramètres à la fois : tous, ou aucun en cas d'erreur
+func (d *Database) SetSettings(values map[string]string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for key, value := range values {
+		if _, err := tx.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
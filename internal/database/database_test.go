@@ -0,0 +1,152 @@
+package database
+
+import (
+	"gestionnaire-telechargement/internal/downloader"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// addTestDownload ouvre une base temporaire et y ajoute un téléchargement
+func addTestDownload(t *testing.T) (*Database, int64) {
+	t.Helper()
+	d := openTestDatabase(t, filepath.Join(t.TempDir(), "goload.db"))
+	id, err := d.AddDownload("http://example.com/fichier.iso", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, id
+}
+
+// L'avancement enregistré est relu à l'identique, octets téléchargés compris
+func TestSaveDownloadState(t *testing.T) {
+	d, id := addTestDownload(t)
+
+	state := downloader.ResumeState{
+		URL:          "http://example.com/fichier.iso",
+		FileName:     "fichier.iso",
+		FilePath:     "/tmp/fichier.iso",
+		Size:         3000,
+		ETag:         `"v1"`,
+		LastModified: "Wed, 21 Oct 2015 07:28:00 GMT",
+		Retries:      2,
+		SpeedLimit:   1024,
+		Checksum:     "sha256:abc",
+		Chunks: []downloader.ChunkInfo{
+			{ID: 1, Start: 0, End: 1000, Size: 1000, Downloaded: 1000, Progress: 1},
+			{ID: 2, Start: 1000, End: 2000, Size: 1000, Downloaded: 250, Progress: 0.25},
+			{ID: 3, Start: 2000, End: 3000, Size: 1000},
+		},
+	}
+	if err := d.SaveDownloadState(id, state); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.GetDownloadState(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Options = downloader.HTTPOptions{}
+	if !reflect.DeepEqual(*got, state) {
+		t.Errorf("état relu %+v, attendu %+v", *got, state)
+	}
+
+	details, err := d.GetDownloadDetails(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.DownloadedSize != 1250 || details.Size != 3000 || details.Retries != 2 || len(details.Chunks) != 3 {
+		t.Errorf("détails %+v, attendu 1250 octets sur 3000 en 3 chunks", details)
+	}
+
+	// Un nouvel état remplace les chunks précédents
+	state.Chunks = state.Chunks[:1]
+	if err := d.SaveDownloadState(id, state); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := d.GetDownloadState(id); err != nil || len(got.Chunks) != 1 {
+		t.Errorf("chunks relus %+v, %v ; attendu 1 chunk", got, err)
+	}
+
+	if got, err := d.GetDownloadState(id + 1); got != nil || err != nil {
+		t.Errorf("état d'un téléchargement inconnu : %+v, %v", got, err)
+	}
+}
+
+// Les options HTTP et les identifiants d'un téléchargement sont relus avec son état
+func TestDownloadOptionsAndCredentials(t *testing.T) {
+	d, id := addTestDownload(t)
+
+	options := downloader.HTTPOptions{
+		Headers:   http.Header{"X-Api-Key": {"secret"}},
+		Cookies:   []*http.Cookie{{Name: "session", Value: "abc"}},
+		UserAgent: "agent/1.0",
+		Referer:   "http://example.com/",
+	}
+	creds := downloader.Credentials{Username: "alice", Password: "mot de passe"}
+	if err := d.SetDownloadOptions(id, options); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetDownloadCredentials(id, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := d.GetDownloadState(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.Options, options) || state.Credentials != creds {
+		t.Errorf("options %+v et identifiants %v relus, attendu %+v et %v", state.Options, state.Credentials, options, creds)
+	}
+
+	// Des identifiants vides suppriment ceux enregistrés
+	if err := d.SetDownloadCredentials(id, downloader.Credentials{}); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := d.GetDownloadState(id); err != nil || !state.Credentials.IsZero() {
+		t.Errorf("identifiants %v après suppression, %v", state.Credentials, err)
+	}
+}
+
+// La date du premier démarrage est conservée ; un nouveau démarrage efface la
+// date de fin et la dernière erreur
+func TestDownloadTimestamps(t *testing.T) {
+	d, id := addTestDownload(t)
+	first := time.Unix(1700000000, 0)
+	failed := first.Add(time.Minute)
+	restarted := first.Add(time.Hour)
+
+	details, err := d.GetDownloadDetails(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.CreatedAt.IsZero() || !details.StartedAt.IsZero() || !details.FinishedAt.IsZero() {
+		t.Errorf("dates d'un téléchargement ajouté : %+v", details)
+	}
+
+	steps := []struct {
+		apply     func() error
+		started   time.Time
+		finished  time.Time
+		lastError string
+	}{
+		{func() error { return d.SetDownloadStarted(id, first) }, first, time.Time{}, ""},
+		{func() error { return d.SetDownloadFinished(id, failed, "connexion refusée") }, first, failed, "connexion refusée"},
+		{func() error { return d.SetDownloadStarted(id, restarted) }, first, time.Time{}, ""},
+	}
+	for i, step := range steps {
+		if err := step.apply(); err != nil {
+			t.Fatal(err)
+		}
+		details, err := d.GetDownloadDetails(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !details.StartedAt.Equal(step.started) || !details.FinishedAt.Equal(step.finished) || details.LastError != step.lastError {
+			t.Errorf("étape %d : démarré %v, terminé %v, erreur %q ; attendu %v, %v, %q",
+				i+1, details.StartedAt, details.FinishedAt, details.LastError, step.started, step.finished, step.lastError)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FileName est le nom du fichier de la base de données
+const FileName = "goloader.db"
+
+// appName nomme le dossier des données de l'application
+const appName = "goload"
+
+// Fichiers que SQLite peut laisser à côté de la base, déplacés avec elle
+var companionSuffixes = []string{"-journal", "-wal", "-shm"}
+
+// DefaultPath retourne l'emplacement par défaut de la base de données :
+// $XDG_DATA_HOME/goload, ou ~/.local/share/goload si la variable n'est pas définie
+func DefaultPath() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(dataHome) {
+		// La spécification XDG demande d'ignorer un chemin relatif
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("impossible de déterminer le dossier personnel : %v", err)
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, appName, FileName), nil
+}
+
+// PortableDir retourne le dossier de l'exécutable, où le mode portable conserve
+// ses données
+func PortableDir() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("impossible de localiser l'exécutable : %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	return filepath.Dir(executable), nil
+}
+
+// MoveLegacyDatabase déplace vers path la base de données que les versions
+// précédentes créaient dans le dossier courant, si path n'existe pas encore.
+// Elle retourne l'ancien emplacement, vide s'il n'y avait rien à déplacer.
+func MoveLegacyDatabase(path string) (string, error) {
+	legacy, err := filepath.Abs(FileName)
+	if err != nil {
+		return "", err
+	}
+	target, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if legacy == target {
+		return "", nil
+	}
+
+	if _, err := os.Stat(target); !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return "", err
+	}
+	// Le journal d'abord : la base ne doit pas arriver sans lui
+	for _, suffix := range companionSuffixes {
+		if err := moveFile(legacy+suffix, target+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	if err := moveFile(legacy, target); err != nil {
+		return "", err
+	}
+	return legacy, nil
+}
+
+// moveFile renomme le fichier, ou le copie puis le supprime s'il change de
+// système de fichiers
+func moveFile(from, to string) error {
+	if _, err := os.Stat(from); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	if err := copyFile(from, to); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return os.Remove(from)
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
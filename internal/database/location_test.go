@@ -0,0 +1,120 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	fallback := filepath.Join(home, ".local", "share", appName, FileName)
+	dataHome := t.TempDir()
+
+	tests := map[string]string{
+		dataHome:  filepath.Join(dataHome, appName, FileName),
+		"":        fallback,
+		"relatif": fallback, // Ignoré, comme le demande la spécification XDG
+	}
+	for value, want := range tests {
+		t.Setenv("XDG_DATA_HOME", value)
+		if got, err := DefaultPath(); err != nil || got != want {
+			t.Errorf("XDG_DATA_HOME=%q : DefaultPath() = %q, %v ; attendu %q", value, got, err, want)
+		}
+	}
+}
+
+func TestPortableDir(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	want, err := filepath.EvalSymlinks(filepath.Dir(executable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := PortableDir(); err != nil || got != want {
+		t.Errorf("PortableDir() = %q, %v ; attendu %q", got, err, want)
+	}
+}
+
+// chdir se place dans un dossier temporaire le temps du test
+func chdir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	// Le dossier temporaire peut être désigné par un lien symbolique
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
+
+// La base du dossier courant est déplacée avec son journal, une seule fois
+func TestMoveLegacyDatabase(t *testing.T) {
+	dir := chdir(t)
+	for name, data := range map[string]string{FileName: "base", FileName + "-wal": "journal"} {
+		if err := os.WriteFile(name, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), "données", FileName)
+	legacy, err := MoveLegacyDatabase(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy != filepath.Join(dir, FileName) {
+		t.Errorf("ancien emplacement %q, attendu %q", legacy, filepath.Join(dir, FileName))
+	}
+	for name, want := range map[string]string{target: "base", target + "-wal": "journal"} {
+		if data, err := os.ReadFile(name); err != nil || string(data) != want {
+			t.Errorf("%s : %q, %v ; attendu %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(FileName); !os.IsNotExist(err) {
+		t.Errorf("l'ancienne base subsiste : %v", err)
+	}
+
+	// Plus rien à déplacer
+	if legacy, err := MoveLegacyDatabase(target); legacy != "" || err != nil {
+		t.Errorf("second déplacement : %q, %v", legacy, err)
+	}
+}
+
+// Une base déjà présente à la destination n'est jamais remplacée
+func TestMoveLegacyDatabaseKeepsTarget(t *testing.T) {
+	chdir(t)
+	if err := os.WriteFile(FileName, []byte("ancienne"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(target, []byte("actuelle"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if legacy, err := MoveLegacyDatabase(target); legacy != "" || err != nil {
+		t.Errorf("MoveLegacyDatabase = %q, %v ; attendu aucun déplacement", legacy, err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "actuelle" {
+		t.Errorf("base de destination remplacée : %q", data)
+	}
+	if _, err := os.Stat(FileName); err != nil {
+		t.Errorf("ancienne base supprimée : %v", err)
+	}
+
+	// La base déjà à sa place n'est pas déplacée sur elle-même
+	if legacy, err := MoveLegacyDatabase(FileName); legacy != "" || err != nil {
+		t.Errorf("MoveLegacyDatabase(%q) = %q, %v", FileName, legacy, err)
+	}
+}
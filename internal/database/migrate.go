@@ -0,0 +1,166 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations du schéma, numérotées "0001_nom.sql" et appliquées dans l'ordre.
+// Une migration publiée n'est jamais modifiée : tout changement du schéma fait
+// l'objet d'un nouveau fichier.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew signale une base de données écrite par une version plus
+// récente du programme, dont le schéma n'est pas connu de celle-ci
+var ErrSchemaTooNew = errors.New("base de données créée par une version plus récente")
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// loadMigrations lit les migrations embarquées, triées par version
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("nom de migration invalide : %s", entry.Name())
+		}
+		query, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, query: string(query)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %d manquante ou en double", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// migrate applique les migrations qui ne l'ont pas encore été, chacune dans sa
+// propre transaction, et refuse une base dont le schéma est plus récent
+func (d *Database) migrate() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`
+	if _, err := d.db.Exec(query); err != nil {
+		return err
+	}
+
+	current, err := d.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := len(migrations); current > latest {
+		return fmt.Errorf("%w : schéma en version %d, version %d au plus prise en charge", ErrSchemaTooNew, current, latest)
+	}
+
+	if current == 0 {
+		if err := d.upgradeLegacySchema(); err != nil {
+			return fmt.Errorf("impossible de mettre à niveau l'ancien schéma : %v", err)
+		}
+	}
+
+	for _, m := range migrations[current:] {
+		if err := d.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) : %v", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// schemaVersion retourne la version de la dernière migration appliquée, 0 pour
+// une base nouvelle ou antérieure aux migrations
+func (d *Database) schemaVersion() (int, error) {
+	var version int
+	err := d.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+func (d *Database) applyMigration(m migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.query); err != nil {
+		return err
+	}
+	query := "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"
+	if _, err := tx.Exec(query, m.version, m.name, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// upgradeLegacySchema ajoute aux bases antérieures aux migrations la colonne
+// size, absente des plus anciennes, pour que la migration initiale trouve la
+// table downloads sous sa forme publiée
+func (d *Database) upgradeLegacySchema() error {
+	return d.addColumnIfMissing("downloads", "size", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// addColumnIfMissing ajoute la colonne à la table si elle existe sans elle ;
+// une table absente sera créée par la migration initiale
+func (d *Database) addColumnIfMissing(table, column, definition string) error {
+	rows, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var exists bool
+	for rows.Next() {
+		var cid int
+		var name, ctype string
+		var notnull, pk int
+		var dfltValue sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+		exists = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if !exists {
+		return nil
+	}
+	_, err = d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
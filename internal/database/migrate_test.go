@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// openTestDatabase ouvre la base située à path et la ferme à la fin du test
+func openTestDatabase(t *testing.T, path string) *Database {
+	t.Helper()
+	d, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.Close)
+	return d
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("aucune migration embarquée")
+	}
+	for i, m := range migrations {
+		if m.version != i+1 || m.name == "" || m.query == "" {
+			t.Errorf("migration %d invalide : version %d, nom %q", i+1, m.version, m.name)
+		}
+	}
+}
+
+func TestMigrateNewDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goload.db")
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := openTestDatabase(t, path)
+	if version, err := d.schemaVersion(); err != nil || version != len(migrations) {
+		t.Fatalf("version %d, %v ; attendu %d", version, err, len(migrations))
+	}
+
+	// Une seconde migration ne rejoue rien
+	if err := d.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&count); err != nil || count != len(migrations) {
+		t.Errorf("%d migrations enregistrées, %v ; attendu %d", count, err, len(migrations))
+	}
+}
+
+// Une version plus ancienne du programme refuse une base au schéma plus récent
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goload.db")
+	d, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := d.schemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', 0)"
+	if _, err := d.db.Exec(query, latest+1); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	if d, err := NewDatabase(path); !errors.Is(err, ErrSchemaTooNew) {
+		if err == nil {
+			d.Close()
+		}
+		t.Fatalf("NewDatabase : %v, attendu ErrSchemaTooNew", err)
+	}
+}
+
+// Une base antérieure aux migrations, sans la colonne size, est mise à niveau
+// sans perdre ses téléchargements
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goload.db")
+	legacy, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"CREATE TABLE downloads (id INTEGER PRIMARY KEY AUTOINCREMENT, url TEXT NOT NULL, status TEXT NOT NULL)",
+		"CREATE TABLE settings (key TEXT PRIMARY KEY, value TEXT)",
+		"INSERT INTO downloads (url, status) VALUES ('https://example.com/a.zip', 'paused')",
+		"INSERT INTO settings (key, value) VALUES ('max_chunks', '8')",
+	} {
+		if _, err := legacy.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy.Close()
+
+	d := openTestDatabase(t, path)
+	downloads, err := d.GetAllDownloads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downloads) != 1 || downloads[0].URL != "https://example.com/a.zip" || downloads[0].Size != 0 {
+		t.Errorf("téléchargements après migration : %+v", downloads)
+	}
+	if value, err := d.GetSetting("max_chunks"); err != nil || value != "8" {
+		t.Errorf("paramètre max_chunks = %q, %v ; attendu 8", value, err)
+	}
+}
@@ -0,0 +1,66 @@
+-- Schéma initial. Les tables downloads et settings existent déjà dans les bases
+-- antérieures aux migrations, sous leur forme publiée que upgradeLegacySchema
+-- complète de la colonne size ; les colonnes suivantes sont ajoutées ici.
+
+CREATE TABLE IF NOT EXISTS downloads (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	status TEXT NOT NULL,
+	size INTEGER NOT NULL DEFAULT 0
+);
+
+ALTER TABLE downloads ADD COLUMN file_path TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN file_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN retry_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN speed_limit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN checksum TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN etag TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN last_modified TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN queue_position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN request_headers TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN cookies TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN referer TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN downloaded INTEGER NOT NULL DEFAULT 0;
+-- Dates en secondes Unix, 0 si inconnues
+ALTER TABLE downloads ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN started_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN finished_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT
+);
+
+-- Avancement de chaque chunk, pour reprendre un téléchargement interrompu
+CREATE TABLE IF NOT EXISTS download_chunks (
+	download_id INTEGER NOT NULL,
+	chunk_id INTEGER NOT NULL,
+	size INTEGER NOT NULL,
+	downloaded INTEGER NOT NULL DEFAULT 0,
+	start_offset INTEGER NOT NULL DEFAULT 0,
+	end_offset INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (download_id, chunk_id)
+);
+
+-- Identifiants tenus à l'écart de la table downloads et de ses URLs : par hôte,
+-- et propres à un téléchargement
+CREATE TABLE IF NOT EXISTS host_credentials (
+	pattern TEXT PRIMARY KEY,
+	username TEXT NOT NULL DEFAULT '',
+	password TEXT NOT NULL DEFAULT '',
+	token TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS download_credentials (
+	download_id INTEGER PRIMARY KEY,
+	username TEXT NOT NULL DEFAULT '',
+	password TEXT NOT NULL DEFAULT '',
+	token TEXT NOT NULL DEFAULT ''
+);
+
+-- Les téléchargements antérieurs à la file d'attente gardent leur ordre d'ajout
+UPDATE downloads SET queue_position = id WHERE queue_position = 0;
+
@@ -0,0 +1,12 @@
+-- Historique des changements de statut des téléchargements, conservé après leur
+-- suppression. from_status est vide à l'ajout du téléchargement.
+CREATE TABLE status_transitions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	download_id INTEGER NOT NULL,
+	from_status TEXT NOT NULL,
+	to_status TEXT NOT NULL,
+	cause TEXT NOT NULL DEFAULT '',
+	at INTEGER NOT NULL
+);
+
+CREATE INDEX status_transitions_download ON status_transitions (download_id);
@@ -0,0 +1,11 @@
+package downloader
+
+import (
+	"os"
+	"syscall"
+)
+
+// allocate réserve les blocs du fichier avec fallocate(2)
+func allocate(f *os.File, size int64) error {
+	return syscall.Fallocate(int(f.Fd()), 0, 0, size)
+}
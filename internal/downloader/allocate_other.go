@@ -0,0 +1,14 @@
+//go:build !linux
+
+package downloader
+
+import (
+	"errors"
+	"os"
+)
+
+// allocate n'est pas disponible sur ce système : preallocateFile se rabat sur
+// un fichier creux
+func allocate(f *os.File, size int64) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,381 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Credentials authentifie les requêtes vers un hôte : nom d'utilisateur et mot de
+// passe pour Basic et Digest, jeton pour Bearer
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// IsZero indique qu'aucun identifiant n'est renseigné
+func (c Credentials) IsZero() bool {
+	return c.Username == "" && c.Password == "" && c.Token == ""
+}
+
+// String masque les secrets : des identifiants affichés par erreur dans un
+// journal n'y laissent que le nom d'utilisateur
+func (c Credentials) String() string {
+	switch {
+	case c.IsZero():
+		return ""
+	case c.Token != "":
+		return "jeton:***"
+	default:
+		return c.Username + ":***"
+	}
+}
+
+// HostCredentials associe des identifiants enregistrés aux hôtes dont le nom
+// correspond au motif, éventuellement avec jokers : "*.example.org"
+type HostCredentials struct {
+	Pattern string
+	Credentials
+}
+
+// splitUserinfo retire les identifiants présents dans l'URL ("https://user:pass@hôte/"),
+// qui ne doivent être ni enregistrés avec elle ni affichés
+func splitUserinfo(rawURL string) (string, Credentials) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL, Credentials{}
+	}
+
+	password, _ := u.User.Password()
+	creds := Credentials{Username: u.User.Username(), Password: password}
+	u.User = nil
+	return u.String(), creds
+}
+
+// hostCredentials résout les identifiants d'un hôte : règles enregistrées, puis
+// fichier .netrc
+type hostCredentials struct {
+	mu      sync.RWMutex
+	entries []HostCredentials
+}
+
+func (c *hostCredentials) set(entries []HostCredentials) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = entries
+}
+
+func (c *hostCredentials) lookup(host string) (Credentials, bool) {
+	c.mu.RLock()
+	for _, entry := range c.entries {
+		if matched, _ := path.Match(strings.ToLower(entry.Pattern), host); matched {
+			c.mu.RUnlock()
+			return entry.Credentials, true
+		}
+	}
+	c.mu.RUnlock()
+
+	return netrcCredentials(host)
+}
+
+// SetHostCredentials remplace les identifiants enregistrés par hôte ; ils
+// s'appliquent aux requêtes des téléchargements suivants
+func (d *Downloader) SetHostCredentials(entries []HostCredentials) {
+	d.credentials.set(entries)
+}
+
+// credentialsLookup retourne la résolution des identifiants d'un téléchargement :
+// ceux saisis pour lui ne sont envoyés qu'à l'hôte et au port de son URL
+func (d *Downloader) credentialsLookup(req Request) func(host string) (Credentials, bool) {
+	var jobHost string
+	if u, err := url.Parse(req.URL); err == nil {
+		jobHost = strings.ToLower(u.Host)
+	}
+	return func(host string) (Credentials, bool) {
+		if host == jobHost && !req.Credentials.IsZero() {
+			return req.Credentials, true
+		}
+		return d.credentials.lookup(hostOf("//" + host))
+	}
+}
+
+// challenge est un défi WWW-Authenticate : schéma en minuscules et paramètres
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges lit les défis de l'en-tête WWW-Authenticate (RFC 7235) ; un
+// même en-tête peut en proposer plusieurs, séparés par des virgules
+func parseChallenges(values []string) []challenge {
+	var challenges []challenge
+	for _, value := range values {
+		s := value
+		for {
+			s = strings.TrimLeft(s, " \t,")
+			if s == "" {
+				break
+			}
+
+			// Schéma, puis paramètres nom=valeur jusqu'au schéma suivant
+			end := strings.IndexAny(s, " \t,")
+			if end < 0 {
+				end = len(s)
+			}
+			c := challenge{scheme: strings.ToLower(s[:end]), params: map[string]string{}}
+			s = s[end:]
+
+			for {
+				rest := strings.TrimLeft(s, " \t,")
+				name, after, found := strings.Cut(rest, "=")
+				if !found || strings.ContainsAny(name, " \t,") {
+					s = rest
+					break
+				}
+				value, remaining := readParamValue(strings.TrimLeft(after, " \t"))
+				c.params[strings.ToLower(strings.TrimSpace(name))] = value
+				s = remaining
+			}
+			challenges = append(challenges, c)
+		}
+	}
+	return challenges
+}
+
+// readParamValue lit une valeur de paramètre, entre guillemets ou non, et
+// retourne le reste de l'en-tête
+func readParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, " \t,")
+		if end < 0 {
+			return s, ""
+		}
+		return s[:end], s[end:]
+	}
+
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				value.WriteByte(s[i])
+			}
+		case '"':
+			return value.String(), s[i+1:]
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return value.String(), ""
+}
+
+// chooseChallenge retient le défi auquel les identifiants permettent de répondre,
+// Digest étant préféré à Basic qui transmet le mot de passe en clair
+func chooseChallenge(challenges []challenge, creds Credentials) *challenge {
+	preferred := []string{"digest", "basic"}
+	if creds.Token != "" {
+		preferred = []string{"bearer"}
+	}
+	for _, scheme := range preferred {
+		for i := range challenges {
+			if challenges[i].scheme == scheme && (scheme != "digest" || digestHash(challenges[i].params["algorithm"]) != nil) {
+				return &challenges[i]
+			}
+		}
+	}
+	return nil
+}
+
+// digestHash retourne la fonction de hachage d'un algorithme Digest supporté
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	default:
+		return nil
+	}
+}
+
+// authState est le schéma négocié avec un hôte, réutilisé d'emblée par les
+// requêtes suivantes pour éviter un aller-retour par chunk
+type authState struct {
+	creds     Credentials
+	challenge *challenge
+	nc        int // Compteur de requêtes sur le nonce Digest courant
+}
+
+// authorization calcule l'en-tête Authorization d'une requête, vide si le schéma
+// n'est pas encore connu
+func (s *authState) authorization(req *http.Request) string {
+	if s.challenge == nil {
+		// Un jeton est envoyé d'emblée : beaucoup d'API répondent 403 ou 404 sans défi
+		if s.creds.Token != "" {
+			return "Bearer " + s.creds.Token
+		}
+		return ""
+	}
+
+	switch s.challenge.scheme {
+	case "bearer":
+		return "Bearer " + s.creds.Token
+	case "basic":
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(s.creds.Username, s.creds.Password)
+		return req.Header.Get("Authorization")
+	case "digest":
+		s.nc++
+		return digestAuthorization(s.creds, s.challenge.params, req.Method, req.URL.RequestURI(), s.nc)
+	default:
+		return ""
+	}
+}
+
+// digestAuthorization calcule la réponse à un défi Digest (RFC 7616)
+func digestAuthorization(creds Credentials, params map[string]string, method, uri string, nc int) string {
+	algorithm := params["algorithm"]
+	newHash := digestHash(algorithm)
+	h := func(parts ...string) string {
+		sum := newHash()
+		io.WriteString(sum, strings.Join(parts, ":"))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	cnonce := make([]byte, 16)
+	rand.Read(cnonce)
+	clientNonce := hex.EncodeToString(cnonce)
+	count := fmt.Sprintf("%08x", nc)
+
+	ha1 := h(creds.Username, params["realm"], creds.Password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = h(ha1, params["nonce"], clientNonce)
+	}
+	ha2 := h(method, uri)
+
+	// qop peut proposer plusieurs valeurs ; seule "auth" est supportée
+	qop := ""
+	for _, value := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(value) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	fields := []string{
+		fmt.Sprintf("username=%q", creds.Username),
+		fmt.Sprintf("realm=%q", params["realm"]),
+		fmt.Sprintf("nonce=%q", params["nonce"]),
+		fmt.Sprintf("uri=%q", uri),
+	}
+	if qop != "" {
+		fields = append(fields,
+			fmt.Sprintf("response=%q", h(ha1, params["nonce"], count, clientNonce, qop, ha2)),
+			"qop="+qop,
+			"nc="+count,
+			fmt.Sprintf("cnonce=%q", clientNonce))
+	} else {
+		fields = append(fields, fmt.Sprintf("response=%q", h(ha1, params["nonce"], ha2)))
+	}
+	if algorithm != "" {
+		fields = append(fields, "algorithm="+algorithm)
+	}
+	if opaque, ok := params["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf("opaque=%q", opaque))
+	}
+	return "Digest " + strings.Join(fields, ", ")
+}
+
+// authTransport répond aux défis 401 avec les identifiants de l'hôte demandé.
+// Les identifiants sont résolus par hôte et port à chaque saut de redirection :
+// ceux d'un hôte ne sont jamais envoyés à un autre.
+type authTransport struct {
+	base   http.RoundTripper
+	lookup func(host string) (Credentials, bool)
+	mu     sync.Mutex
+	hosts  map[string]*authState // nil si l'hôte n'a pas d'identifiants
+}
+
+func newAuthTransport(base http.RoundTripper, lookup func(host string) (Credentials, bool)) *authTransport {
+	return &authTransport{base: base, lookup: lookup, hosts: make(map[string]*authState)}
+}
+
+func (t *authTransport) state(host string) *authState {
+	state, known := t.hosts[host]
+	if !known {
+		if creds, ok := t.lookup(host); ok && !creds.IsZero() {
+			state = &authState{creds: creds}
+		}
+		t.hosts[host] = state
+	}
+	return state
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Host)
+	if req.Header.Get("Authorization") != "" {
+		// En-tête fourni explicitement par les options HTTP du téléchargement
+		return t.base.RoundTrip(req)
+	}
+
+	t.mu.Lock()
+	state := t.state(host)
+	var sent string
+	if state != nil {
+		sent = state.authorization(req)
+	}
+	t.mu.Unlock()
+	if state == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	resp, err := t.base.RoundTrip(withAuthorization(req, sent))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// Une requête avec un corps ne peut pas être renvoyée
+	if req.Body != nil && req.Body != http.NoBody {
+		return resp, nil
+	}
+
+	t.mu.Lock()
+	c := chooseChallenge(parseChallenges(resp.Header.Values("WWW-Authenticate")), state.creds)
+	// Ne renvoyer la requête qu'avec un nouveau schéma ou un nonce expiré : un refus
+	// des mêmes identifiants est définitif
+	retry := c != nil && (sent == "" || !strings.EqualFold(strings.Fields(sent)[0], c.scheme) ||
+		strings.EqualFold(c.params["stale"], "true"))
+	if retry {
+		state.challenge = c
+		state.nc = 0
+		sent = state.authorization(req)
+	}
+	t.mu.Unlock()
+	if !retry || sent == "" {
+		return resp, nil
+	}
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return t.base.RoundTrip(withAuthorization(req, sent))
+}
+
+// withAuthorization retourne une copie de req portant l'en-tête Authorization
+func withAuthorization(req *http.Request, authorization string) *http.Request {
+	if authorization == "" {
+		return req
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", authorization)
+	return r
+}
@@ -0,0 +1,303 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Taille maximale d'un fichier d'empreintes, pour ne pas lire un fichier quelconque en mémoire
+const maxChecksumFileSize = 1 << 20
+
+// HashAlgorithm identifie un algorithme d'empreinte supporté
+type HashAlgorithm string
+
+const (
+	MD5    HashAlgorithm = "md5"
+	SHA1   HashAlgorithm = "sha1"
+	SHA256 HashAlgorithm = "sha256"
+	SHA512 HashAlgorithm = "sha512"
+)
+
+// ErrCorrupted signale que le fichier téléchargé ne correspond pas à l'empreinte attendue
+var ErrCorrupted = errors.New("le fichier téléchargé ne correspond pas à l'empreinte attendue")
+
+// Noms des algorithmes dans l'en-tête Digest (RFC 3230), du plus fort au plus faible
+var digestAlgorithms = []struct {
+	name      string
+	algorithm HashAlgorithm
+}{
+	{"sha-512", SHA512},
+	{"sha-256", SHA256},
+	{"sha", SHA1},
+	{"md5", MD5},
+}
+
+// Checksum est une empreinte attendue, en hexadécimal minuscule
+type Checksum struct {
+	Algorithm HashAlgorithm
+	Value     string
+}
+
+func (c Checksum) String() string {
+	return string(c.Algorithm) + ":" + c.Value
+}
+
+func (a HashAlgorithm) new() hash.Hash {
+	switch a {
+	case MD5:
+		return md5.New()
+	case SHA1:
+		return sha1.New()
+	case SHA256:
+		return sha256.New()
+	case SHA512:
+		return sha512.New()
+	default:
+		return nil
+	}
+}
+
+// algorithmForLength déduit l'algorithme de la longueur de l'empreinte hexadécimale
+func algorithmForLength(length int) HashAlgorithm {
+	switch length {
+	case 2 * md5.Size:
+		return MD5
+	case 2 * sha1.Size:
+		return SHA1
+	case 2 * sha256.Size:
+		return SHA256
+	case 2 * sha512.Size:
+		return SHA512
+	default:
+		return ""
+	}
+}
+
+// parseAlgorithm reconnaît "md5", "SHA-1", "sha256", "SHA512"...
+func parseAlgorithm(name string) HashAlgorithm {
+	name = strings.ToLower(strings.ReplaceAll(name, "-", ""))
+	switch algorithm := HashAlgorithm(name); algorithm {
+	case MD5, SHA1, SHA256, SHA512:
+		return algorithm
+	default:
+		return ""
+	}
+}
+
+// ParseChecksum interprète une empreinte saisie sous la forme "sha256:<hex>" ou
+// simplement "<hex>", l'algorithme étant alors déduit de la longueur
+func ParseChecksum(value string) (Checksum, error) {
+	value = strings.TrimSpace(value)
+	algorithm := HashAlgorithm("")
+	if name, digest, found := strings.Cut(value, ":"); found {
+		if algorithm = parseAlgorithm(name); algorithm == "" {
+			return Checksum{}, fmt.Errorf("algorithme d'empreinte non supporté : %s", name)
+		}
+		value = strings.TrimSpace(digest)
+	}
+
+	value = strings.ToLower(value)
+	if _, err := hex.DecodeString(value); err != nil {
+		return Checksum{}, fmt.Errorf("empreinte invalide : %s", value)
+	}
+	if algorithm == "" {
+		algorithm = algorithmForLength(len(value))
+	}
+	if algorithm == "" || len(value) != 2*algorithm.new().Size() {
+		return Checksum{}, fmt.Errorf("empreinte invalide : %s", value)
+	}
+
+	return Checksum{Algorithm: algorithm, Value: value}, nil
+}
+
+// isChecksumURL indique si la valeur saisie désigne un fichier d'empreintes distant
+func isChecksumURL(value string) bool {
+	u, err := url.Parse(strings.TrimSpace(value))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ValidateChecksum vérifie une empreinte saisie par l'utilisateur ; une URL de
+// fichier d'empreintes n'est contrôlée qu'au téléchargement
+func ValidateChecksum(value string) error {
+	if strings.TrimSpace(value) == "" || isChecksumURL(value) {
+		return nil
+	}
+	_, err := ParseChecksum(value)
+	return err
+}
+
+// expectedChecksum détermine l'empreinte attendue : celle de la requête, saisie
+// directement ou lue dans un fichier voisin (.sha256, SHA256SUMS...), sinon celle
+// annoncée par le serveur dans l'en-tête Digest ; nil si aucune n'est connue
+func (d *Downloader) expectedChecksum(ctx context.Context, j *job, resp *http.Response, fileName string) (*Checksum, error) {
+	switch {
+	case j.Checksum == "":
+		return digestFromHeader(resp.Header.Values("Digest")), nil
+	case isChecksumURL(j.Checksum):
+		var sum *Checksum
+		err := d.withRetry(ctx, j, func() error {
+			var err error
+			sum, err = d.fetchChecksum(ctx, j, j.Checksum, fileName)
+			return err
+		})
+		return sum, err
+	default:
+		sum, err := ParseChecksum(j.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		return &sum, nil
+	}
+}
+
+// fetchChecksum télécharge un fichier d'empreintes et y cherche celle de fileName
+func (d *Downloader) fetchChecksum(ctx context.Context, j *job, checksumURL, fileName string) (*Checksum, error) {
+	req, err := d.newRequest(ctx, j, http.MethodGet, checksumURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		return nil, retryable(fmt.Errorf("erreur lors de la récupération du fichier d'empreintes : %v", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumFileSize))
+	if err != nil {
+		return nil, retryable(fmt.Errorf("erreur lors de la lecture du fichier d'empreintes : %v", err))
+	}
+
+	sum, err := parseChecksumFile(data, algorithmFromFileName(resp.Request.URL.Path), fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &sum, nil
+}
+
+// algorithmFromFileName déduit l'algorithme du nom du fichier d'empreintes
+// ("fichier.sha256", "SHA512SUMS", "md5sum.txt"...)
+func algorithmFromFileName(p string) HashAlgorithm {
+	name := strings.ToLower(path.Base(p))
+	// sha512 et sha256 avant sha1, qui en est un préfixe
+	for _, algorithm := range []HashAlgorithm{SHA512, SHA256, SHA1, MD5} {
+		if strings.Contains(name, string(algorithm)) {
+			return algorithm
+		}
+	}
+	return ""
+}
+
+// parseChecksumFile lit un fichier au format de sha256sum ("<hex>  nom", "<hex> *nom")
+// ou au format BSD ("SHA256 (nom) = <hex>") ; un fichier ne contenant qu'une
+// empreinte sans nom s'applique au fichier téléchargé
+func parseChecksumFile(data []byte, algorithm HashAlgorithm, fileName string) (Checksum, error) {
+	var single []string
+	lines := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines++
+
+		lineAlgorithm, value, name := algorithm, "", ""
+		if tag, rest, found := strings.Cut(line, " ("); found && strings.Contains(rest, ") = ") {
+			// Format BSD : l'algorithme est indiqué sur chaque ligne
+			i := strings.LastIndex(rest, ") = ")
+			lineAlgorithm, name, value = parseAlgorithm(tag), rest[:i], rest[i+len(") = "):]
+		} else {
+			fields := strings.SplitN(line, " ", 2)
+			value = fields[0]
+			if len(fields) == 2 {
+				name = strings.TrimPrefix(strings.TrimLeft(fields[1], " "), "*")
+			}
+		}
+
+		candidate := value
+		if lineAlgorithm != "" {
+			candidate = string(lineAlgorithm) + ":" + value
+		}
+		if name == "" {
+			single = append(single, candidate)
+			continue
+		}
+		if path.Base(strings.TrimPrefix(name, "./")) == fileName {
+			return ParseChecksum(candidate)
+		}
+	}
+
+	if lines == 1 && len(single) == 1 {
+		return ParseChecksum(single[0])
+	}
+	return Checksum{}, fmt.Errorf("aucune empreinte pour %s dans le fichier d'empreintes", fileName)
+}
+
+// digestFromHeader retourne l'empreinte la plus forte annoncée par l'en-tête
+// Digest, encodée en base64 selon la RFC 3230
+func digestFromHeader(values []string) *Checksum {
+	digests := map[string]string{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			name, encoded, found := strings.Cut(strings.TrimSpace(item), "=")
+			if found {
+				digests[strings.ToLower(name)] = encoded
+			}
+		}
+	}
+
+	for _, candidate := range digestAlgorithms {
+		encoded, ok := digests[candidate.name]
+		if !ok {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != candidate.algorithm.new().Size() {
+			continue
+		}
+		return &Checksum{Algorithm: candidate.algorithm, Value: hex.EncodeToString(raw)}
+	}
+	return nil
+}
+
+// verifyChecksum calcule l'empreinte du fichier et la compare à celle attendue
+func verifyChecksum(filePath string, expected Checksum) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("impossible d'ouvrir le fichier à vérifier : %v", err)
+	}
+	defer f.Close()
+
+	h := expected.Algorithm.new()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("impossible de lire le fichier à vérifier : %v", err)
+	}
+
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected.Value {
+		return fmt.Errorf("%w : %s attendu, %s:%s obtenu", ErrCorrupted, expected, expected.Algorithm, actual)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Empreintes de "abc"
+const (
+	abcMD5    = "900150983cd24fb0d6963f7d28e17f72"
+	abcSHA1   = "a9993e364706816aba3e25717850c26c9cd0d89d"
+	abcSHA256 = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+)
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Checksum
+		err   bool
+	}{
+		{value: "sha256:" + abcSHA256, want: Checksum{SHA256, abcSHA256}},
+		{value: "SHA-256: BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD", want: Checksum{SHA256, abcSHA256}},
+		{value: "  " + abcMD5 + "\n", want: Checksum{MD5, abcMD5}},
+		{value: abcSHA1, want: Checksum{SHA1, abcSHA1}},
+		{value: "md5:" + abcSHA256, err: true}, // Longueur incohérente avec l'algorithme
+		{value: "crc32:352441c2", err: true},
+		{value: "xyz", err: true},
+		{value: "abcd", err: true}, // Longueur qui ne correspond à aucun algorithme
+		{value: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseChecksum(tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseChecksum(%q) = %v, %v ; attendu %v, erreur %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseChecksumFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		algorithm HashAlgorithm
+		want      Checksum
+		err       bool
+	}{
+		{
+			name:      "sha256sum",
+			data:      "# empreintes\n" + abcMD5 + abcMD5 + "  autre.iso\n" + abcSHA256 + "  fichier.iso\n",
+			algorithm: SHA256,
+			want:      Checksum{SHA256, abcSHA256},
+		},
+		{
+			name: "mode binaire et chemin relatif",
+			data: abcSHA256 + " *./dist/fichier.iso\n",
+			want: Checksum{SHA256, abcSHA256},
+		},
+		{
+			name: "BSD",
+			data: "MD5 (autre.iso) = " + abcMD5 + "\nSHA1 (fichier.iso) = " + abcSHA1 + "\n",
+			want: Checksum{SHA1, abcSHA1},
+		},
+		{
+			name:      "empreinte seule",
+			data:      abcMD5 + "\n",
+			algorithm: MD5,
+			want:      Checksum{MD5, abcMD5},
+		},
+		{
+			name: "fichier absent",
+			data: abcSHA256 + "  autre.iso\n",
+			err:  true,
+		},
+		{
+			name: "plusieurs empreintes sans nom",
+			data: abcMD5 + "\n" + abcSHA1 + "\n",
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseChecksumFile([]byte(tt.data), tt.algorithm, "fichier.iso")
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%s : %v, %v ; attendu %v, erreur %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestAlgorithmFromFileName(t *testing.T) {
+	tests := map[string]HashAlgorithm{
+		"/pub/fichier.iso.sha256": SHA256,
+		"SHA512SUMS":              SHA512,
+		"sha1sums.txt":            SHA1,
+		"md5sum.txt":              MD5,
+		"CHECKSUMS":               "",
+	}
+	for name, want := range tests {
+		if got := algorithmFromFileName(name); got != want {
+			t.Errorf("algorithmFromFileName(%q) = %q, attendu %q", name, got, want)
+		}
+	}
+}
+
+func TestDigestFromHeader(t *testing.T) {
+	// Base64 des empreintes de "abc"
+	const md5Digest = "kAFQmDzST7DWlj99KOF/cg=="
+	const sha256Digest = "ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0="
+
+	tests := []struct {
+		values []string
+		want   *Checksum
+	}{
+		{nil, nil},
+		{[]string{"MD5=" + md5Digest}, &Checksum{MD5, abcMD5}},
+		{[]string{"md5=" + md5Digest + ", SHA-256=" + sha256Digest}, &Checksum{SHA256, abcSHA256}},
+		{[]string{"md5=" + md5Digest, "sha-256=" + sha256Digest}, &Checksum{SHA256, abcSHA256}},
+		{[]string{"sha-256=invalide, md5=" + md5Digest}, &Checksum{MD5, abcMD5}},
+		{[]string{"crc32c=NbYXlw=="}, nil},
+	}
+	for _, tt := range tests {
+		got := digestFromHeader(tt.values)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("digestFromHeader(%q) = %v, attendu %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "abc.txt")
+	if err := os.WriteFile(filePath, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyChecksum(filePath, Checksum{SHA256, abcSHA256}); err != nil {
+		t.Errorf("empreinte correcte refusée : %v", err)
+	}
+	if err := verifyChecksum(filePath, Checksum{MD5, abcSHA1[:32]}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("empreinte incorrecte : %v, attendu ErrCorrupted", err)
+	}
+}
@@ -0,0 +1,508 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Taille des blocs copiés entre deux vérifications de pause/annulation
+const copyBlockSize = 32 * 1024
+
+// Taille minimale d'un segment créé par découpage : en dessous, ouvrir une
+// nouvelle connexion coûte plus qu'elle ne fait gagner
+const minSplitSize = 1 << 20
+
+// downloadProgress agrège la progression de tous les chunks d'un téléchargement.
+// Les chunks ne sont lus et modifiés que sous mu : un chunk peut être raccourci
+// par un autre worker pendant son téléchargement.
+type downloadProgress struct {
+	mu           sync.Mutex
+	total        int64
+	downloaded   int64
+	chunks       []ChunkInfo
+	claimed      []bool // Chunks ayant déjà un worker pendant cette session
+	finalized    bool   // Fichier temporaire renommé, ou jamais créé
+	filePath     string
+	etag         string
+	lastModified string
+	lastSave     time.Time
+}
+
+func newDownloadProgress(total int64, chunks []ChunkInfo) *downloadProgress {
+	p := &downloadProgress{
+		total:    total,
+		chunks:   withOffsets(chunks),
+		claimed:  make([]bool, len(chunks)),
+		lastSave: time.Now(),
+	}
+	// Tenir compte des octets déjà écrits lors d'une reprise
+	for _, chunk := range chunks {
+		p.downloaded += chunk.Downloaded
+	}
+	return p
+}
+
+// withOffsets complète les offsets des chunks enregistrés avant leur
+// introduction, qui se suivaient dans l'ordre de leurs IDs
+func withOffsets(chunks []ChunkInfo) []ChunkInfo {
+	for _, chunk := range chunks {
+		if chunk.End != 0 {
+			return chunks
+		}
+	}
+
+	var offset int64
+	for i := range chunks {
+		chunks[i].Start = offset
+		chunks[i].End = offset + chunks[i].Size
+		offset = chunks[i].End
+	}
+	return chunks
+}
+
+// add comptabilise n octets reçus pour le chunk
+func (p *downloadProgress) add(index int, n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chunk := &p.chunks[index]
+	chunk.Downloaded += n
+	if chunk.Size > 0 {
+		chunk.Progress = float64(chunk.Downloaded) / float64(chunk.Size)
+	}
+	p.downloaded += n
+}
+
+// bytes retourne les octets écrits et la taille du fichier, négative si inconnue
+func (p *downloadProgress) bytes() (downloaded, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.downloaded, p.total
+}
+
+// resetChunk remet le chunk à zéro avant de le retélécharger entièrement
+func (p *downloadProgress) resetChunk(index int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.downloaded -= p.chunks[index].Downloaded
+	p.chunks[index].Downloaded = 0
+	p.chunks[index].Progress = 0
+}
+
+// settle fixe la taille d'un fichier reçu sans taille annoncée, une fois sa fin
+// atteinte : c'est celle des octets écrits
+func (p *downloadProgress) settle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.total > 0 || len(p.chunks) != 1 {
+		return
+	}
+	p.total = p.downloaded
+	p.chunks[0].End = p.downloaded
+	p.chunks[0].Size = p.downloaded
+	p.chunks[0].Progress = 1
+}
+
+// markComplete marque tous les chunks comme entièrement téléchargés
+func (p *downloadProgress) markComplete() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.chunks {
+		p.chunks[i].Downloaded = p.chunks[i].Size
+		p.chunks[i].Progress = 1
+	}
+	p.downloaded = p.total
+}
+
+// reset remet tous les chunks à zéro, le fichier devant être retéléchargé entièrement
+func (p *downloadProgress) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.chunks {
+		p.chunks[i].Downloaded = 0
+		p.chunks[i].Progress = 0
+	}
+	p.downloaded = 0
+}
+
+// position retourne l'offset du prochain octet à écrire dans le chunk et le
+// nombre d'octets restants, -1 si la taille du fichier est inconnue
+func (p *downloadProgress) position(index int) (offset, remaining int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chunk := p.chunks[index]
+	offset = chunk.Start + chunk.Downloaded
+	if p.total <= 0 {
+		return offset, -1
+	}
+	return offset, chunk.End - offset
+}
+
+// next attribue un chunk à un worker : d'abord un chunk inachevé qui n'a pas
+// encore de worker, sinon la seconde moitié du chunk en cours ayant le plus
+// d'octets restants. false s'il ne reste rien d'assez grand à partager.
+func (p *downloadProgress) next() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, chunk := range p.chunks {
+		if !p.claimed[i] && chunk.Downloaded < chunk.Size {
+			p.claimed[i] = true
+			return i, true
+		}
+	}
+
+	victim, largest := -1, int64(0)
+	for i, chunk := range p.chunks {
+		if remaining := chunk.Size - chunk.Downloaded; p.claimed[i] && remaining > largest {
+			victim, largest = i, remaining
+		}
+	}
+	if victim < 0 || largest < 2*minSplitSize {
+		return 0, false
+	}
+
+	// La moitié laissée au worker en place dépasse largement le bloc qu'il est en
+	// train d'écrire : les deux workers n'écrivent jamais les mêmes octets
+	v := &p.chunks[victim]
+	split := v.Start + v.Downloaded + largest/2
+	stolen := ChunkInfo{
+		ID:    p.nextID(),
+		Start: split,
+		End:   v.End,
+		Size:  v.End - split,
+	}
+	v.End = split
+	v.Size = split - v.Start
+	v.Progress = float64(v.Downloaded) / float64(v.Size)
+
+	p.chunks = append(p.chunks, stolen)
+	p.claimed = append(p.claimed, true)
+	return len(p.chunks) - 1, true
+}
+
+func (p *downloadProgress) nextID() int {
+	id := 0
+	for _, chunk := range p.chunks {
+		id = max(id, chunk.ID)
+	}
+	return id + 1
+}
+
+// finalize indique que le fichier temporaire n'existe plus : l'état de reprise
+// ne doit plus être écrit à côté de lui
+func (p *downloadProgress) finalize() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.finalized = true
+}
+
+func (p *downloadProgress) isFinalized() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.finalized
+}
+
+// shouldSave indique si l'avancement doit être persisté, au plus une fois par intervalle
+func (p *downloadProgress) shouldSave() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.lastSave) < stateSaveInterval {
+		return false
+	}
+	p.lastSave = time.Now()
+	return true
+}
+
+// state retourne un instantané de l'avancement à persister
+func (p *downloadProgress) state() ResumeState {
+	p.mu.Lock()
+	total := p.total // Fixée par settle en fin de téléchargement si elle était inconnue
+	p.mu.Unlock()
+
+	return ResumeState{
+		FileName:     filepath.Base(p.filePath),
+		FilePath:     p.filePath,
+		Size:         total,
+		ETag:         p.etag,
+		LastModified: p.lastModified,
+		Chunks:       p.snapshot(),
+	}
+}
+
+// snapshot retourne une copie de l'état courant des chunks
+func (p *downloadProgress) snapshot() []ChunkInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chunks := make([]ChunkInfo, len(p.chunks))
+	copy(chunks, p.chunks)
+	return chunks
+}
+
+// splitChunks découpe totalSize en count chunks contigus, le dernier absorbant le reste
+func splitChunks(totalSize int64, count int) []ChunkInfo {
+	if count < 1 {
+		count = 1
+	}
+	// Éviter les chunks vides pour les petits fichiers
+	if totalSize > 0 && int64(count) > totalSize {
+		count = int(totalSize)
+	}
+
+	chunkSize := totalSize / int64(count)
+	chunks := make([]ChunkInfo, count)
+	for i := 0; i < count; i++ {
+		chunks[i] = ChunkInfo{
+			ID:    i + 1,
+			Start: chunkSize * int64(i),
+			End:   chunkSize * int64(i+1),
+			Size:  chunkSize,
+		}
+	}
+	// Ajuster la taille du dernier chunk
+	chunks[count-1].End = totalSize
+	chunks[count-1].Size = totalSize - chunks[count-1].Start
+
+	return chunks
+}
+
+// fileWriteError distingue les erreurs d'écriture sur disque, définitives, des
+// erreurs de lecture réseau, qui justifient une nouvelle tentative
+type fileWriteError struct {
+	err error
+}
+
+func (e *fileWriteError) Error() string {
+	return e.err.Error()
+}
+
+// fileWriter marque les erreurs d'écriture de w comme fileWriteError
+type fileWriter struct {
+	w io.Writer
+}
+
+func (f fileWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		err = &fileWriteError{err: err}
+	}
+	return n, err
+}
+
+// supportsRanges indique si le serveur annonce le support des requêtes partielles
+func supportsRanges(resp *http.Response) bool {
+	return strings.EqualFold(strings.TrimSpace(resp.Header.Get("Accept-Ranges")), "bytes")
+}
+
+// downloadStream télécharge le fichier sur une seule connexion ; sans requête
+// Range, chaque nouvelle tentative repart du début du fichier
+func (d *Downloader) downloadStream(ctx context.Context, j *job, out *os.File, progress *downloadProgress) error {
+	err := d.withRetry(ctx, j, func() error {
+		progress.resetChunk(0)
+		return d.streamOnce(ctx, j, out, progress)
+	})
+	if err == nil {
+		progress.settle()
+	}
+	return err
+}
+
+func (d *Downloader) streamOnce(ctx context.Context, j *job, out *os.File, progress *downloadProgress) error {
+	release, err := d.connections.acquire(ctx, j.host)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Envoyer une requête GET pour télécharger le fichier
+	req, err := d.newRequest(ctx, j, http.MethodGet, j.URL)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return retryable(fmt.Errorf("erreur lors du téléchargement : %v", err))
+	}
+	defer resp.Body.Close()
+
+	// Vérifier le code de statut de la réponse
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return d.copyChunk(ctx, j, out, resp.Body, 0, progress)
+}
+
+// downloadChunks répartit les chunks entre des workers, chacun sur sa propre
+// connexion avec une requête Range. Un worker qui termine son chunk reprend la
+// seconde moitié du chunk le plus en retard, pour qu'une connexion lente ne
+// retarde pas tout le fichier. Un chunk en erreur est réessayé seul, à partir
+// de ses octets déjà écrits, et son échec définitif interrompt les autres.
+func (d *Downloader) downloadChunks(ctx context.Context, j *job, out *os.File, progress *downloadProgress) error {
+	chunksCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	for w := 0; w < d.chunkCount(j.host); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunksCtx.Err() == nil {
+				index, ok := progress.next()
+				if !ok {
+					return
+				}
+				err := d.withRetry(chunksCtx, j, func() error {
+					return d.downloadChunk(chunksCtx, j, out, index, progress)
+				})
+				if err != nil {
+					cancel(fmt.Errorf("chunk %d : %w", index+1, err))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Une annulation extérieure prime sur les erreurs qu'elle a provoquées
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	if chunksCtx.Err() != nil {
+		return context.Cause(chunksCtx)
+	}
+	return nil
+}
+
+func (d *Downloader) downloadChunk(ctx context.Context, j *job, out *os.File, index int, progress *downloadProgress) error {
+	// Un chunk terminé lors d'une session précédente n'est pas retéléchargé
+	start, remaining := progress.position(index)
+	if remaining <= 0 {
+		return nil
+	}
+
+	// Attendre une connexion libre vers l'hôte, partagée avec les autres téléchargements
+	release, err := d.connections.acquire(ctx, j.host)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// La fin demandée est celle du moment : si le chunk est partagé entre-temps,
+	// copyChunk s'arrête à sa nouvelle fin
+	start, remaining = progress.position(index)
+	if remaining <= 0 {
+		return nil
+	}
+	end := start + remaining - 1
+
+	req, err := d.newRequest(ctx, j, http.MethodGet, j.URL)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	validator := ifRange(progress.etag, progress.lastModified)
+	if validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return retryable(fmt.Errorf("erreur lors du téléchargement : %v", err))
+	}
+	defer resp.Body.Close()
+
+	// Avec If-Range, un 200 signifie que le fichier distant a changé ; sans,
+	// que le serveur ignore l'en-tête Range et renvoie le fichier complet
+	if resp.StatusCode == http.StatusOK && validator != "" {
+		return fmt.Errorf("le fichier distant a été modifié depuis le début du téléchargement")
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return responseError(resp)
+	}
+
+	return d.copyChunk(ctx, j, out, resp.Body, index, progress)
+}
+
+// copyChunk écrit le corps reçu à la suite des octets déjà écrits du chunk, en
+// s'arrêtant à la fin du chunk même si elle a avancé depuis la requête
+func (d *Downloader) copyChunk(ctx context.Context, j *job, out io.WriterAt, body io.Reader, index int, progress *downloadProgress) error {
+	// Limiter le débit du téléchargement puis le débit global
+	reader := &limitedReader{
+		ctx:      ctx,
+		reader:   body,
+		limiters: []*RateLimiter{j.limiter, d.limiter},
+	}
+	var copied int64 // Octets écrits par cette tentative
+
+	for {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		offset, remaining := progress.position(index)
+		if remaining == 0 {
+			return nil
+		}
+		block := int64(copyBlockSize)
+		if remaining > 0 && remaining < block {
+			block = remaining
+		}
+
+		writer := fileWriter{io.NewOffsetWriter(out, offset)}
+		n, err := io.CopyN(writer, reader, block)
+		if n > 0 {
+			copied += n
+			progress.add(index, n)
+			d.emitProgress(EventProgress, j)
+			if progress.shouldSave() {
+				d.saveState(j, progress)
+			}
+		}
+		if err == io.EOF {
+			// Sans taille connue, la fin du corps est la fin du fichier
+			if remaining < 0 {
+				return nil
+			}
+			return &retryableError{err: fmt.Errorf("connexion interrompue, %d octets manquants", remaining-n), progressed: copied > 0}
+		}
+		if err != nil {
+			// La lecture du corps échoue aussi lorsque le contexte est annulé
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			var writeErr *fileWriteError
+			if errors.As(err, &writeErr) {
+				if err := noSpaceError(filepath.Dir(progress.filePath), writeErr.err); err != nil {
+					return err
+				}
+				return fmt.Errorf("erreur lors de l'écriture du fichier : %v", writeErr.err)
+			}
+			return &retryableError{err: fmt.Errorf("erreur lors de la lecture des données : %v", err), progressed: copied > 0}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"slices"
+	"testing"
+)
+
+// checkContiguous vérifie que les chunks, triés par offset, couvrent le fichier
+// sans trou ni recouvrement
+func checkContiguous(t *testing.T, chunks []ChunkInfo, total int64) {
+	t.Helper()
+	chunks = slices.Clone(chunks)
+	slices.SortFunc(chunks, func(a, b ChunkInfo) int { return int(a.Start - b.Start) })
+
+	var offset int64
+	for _, chunk := range chunks {
+		if chunk.Start != offset || chunk.Size != chunk.End-chunk.Start {
+			t.Fatalf("chunks non contigus : %+v", chunks)
+		}
+		offset = chunk.End
+	}
+	if offset != total {
+		t.Fatalf("les chunks couvrent %d octets sur %d : %+v", offset, total, chunks)
+	}
+}
+
+func TestProgressNext(t *testing.T) {
+	const total = 8 * minSplitSize
+	p := newDownloadProgress(total, splitChunks(total, 2))
+
+	// Les chunks sans worker sont attribués en premier
+	for want := range 2 {
+		if i, ok := p.next(); !ok || i != want {
+			t.Fatalf("next() = %d, %v ; attendu %d", i, ok, want)
+		}
+	}
+
+	// Le premier chunk a le plus d'octets restants : sa seconde moitié est partagée
+	p.add(1, 2*minSplitSize)
+	i, ok := p.next()
+	if !ok || i != 2 {
+		t.Fatalf("next() = %d, %v ; attendu le nouveau chunk 2", i, ok)
+	}
+	chunks := p.snapshot()
+	if chunks[0].End != 2*minSplitSize || chunks[2].Start != 2*minSplitSize || chunks[2].End != 4*minSplitSize {
+		t.Errorf("découpage inattendu : %+v", chunks)
+	}
+	if chunks[2].ID != 3 {
+		t.Errorf("ID du nouveau chunk %d, attendu 3", chunks[2].ID)
+	}
+	checkContiguous(t, chunks, total)
+
+	// À égalité d'octets restants, le premier chunk trouvé est partagé
+	p.add(0, minSplitSize)
+	i, ok = p.next()
+	chunks = p.snapshot()
+	if !ok || i != 3 || chunks[1].End != 7*minSplitSize || chunks[3].Start != 7*minSplitSize {
+		t.Fatalf("next() = %d, %v ; découpage inattendu : %+v", i, ok, chunks)
+	}
+	checkContiguous(t, chunks, total)
+}
+
+func TestProgressNextSplitsRemainingBytes(t *testing.T) {
+	const total = 8 * minSplitSize
+	p := newDownloadProgress(total, splitChunks(total, 1))
+	p.next()
+	p.add(0, 4*minSplitSize)
+
+	i, ok := p.next()
+	if !ok {
+		t.Fatal("le chunk en cours n'a pas été partagé")
+	}
+	chunks := p.snapshot()
+	// 4 Mio restants à partir de l'offset 4 Mio : découpe à 6 Mio
+	if chunks[0].End != 6*minSplitSize || chunks[i].Start != 6*minSplitSize || chunks[i].End != total {
+		t.Errorf("découpage inattendu : %+v", chunks)
+	}
+	if chunks[0].Progress != float64(4)/6 {
+		t.Errorf("progression du chunk raccourci %v, attendu 4/6", chunks[0].Progress)
+	}
+	checkContiguous(t, chunks, total)
+}
+
+func TestProgressNextTooSmall(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      int64
+		downloaded int64
+		ok         bool
+	}{
+		{"assez grand", 2 * minSplitSize, 0, true},
+		{"trop petit", 2*minSplitSize - 1, 0, false},
+		{"presque terminé", 4 * minSplitSize, 3 * minSplitSize, false},
+		{"terminé", 4 * minSplitSize, 4 * minSplitSize, false},
+	}
+	for _, tt := range tests {
+		p := newDownloadProgress(tt.total, splitChunks(tt.total, 1))
+		p.next()
+		p.add(0, tt.downloaded)
+		if _, ok := p.next(); ok != tt.ok {
+			t.Errorf("%s : next() = %v, attendu %v", tt.name, ok, tt.ok)
+		}
+		checkContiguous(t, p.snapshot(), tt.total)
+	}
+}
@@ -0,0 +1,145 @@
+package downloader
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CollisionPolicy indique que faire lorsque le fichier de destination existe déjà
+type CollisionPolicy string
+
+const (
+	// CollisionOverwrite remplace le fichier existant
+	CollisionOverwrite CollisionPolicy = "overwrite"
+	// CollisionRename écrit dans "nom (1).ext", "nom (2).ext", ...
+	CollisionRename CollisionPolicy = "rename"
+	// CollisionSkip ne télécharge pas si le fichier existant semble identique
+	// (voir sameFile) ; sinon le fichier est renommé
+	CollisionSkip CollisionPolicy = "skip"
+	// CollisionAsk délègue le choix à OnCollision
+	CollisionAsk CollisionPolicy = "ask"
+)
+
+// Nombre maximal de suffixes essayés avant d'abandonner le renommage
+const maxRenameAttempts = 1000
+
+// Longueur du plus long suffixe ajouté par createRenamed
+const maxRenameSuffixLength = len(" (1000)")
+
+// errSkipped signale que le fichier existant a été conservé
+var errSkipped = errors.New("fichier existant conservé")
+
+// ParseCollisionPolicy convertit la valeur enregistrée dans les paramètres ;
+// une valeur inconnue ou vide donne le renommage, qui ne détruit rien
+func ParseCollisionPolicy(value string) CollisionPolicy {
+	switch policy := CollisionPolicy(value); policy {
+	case CollisionOverwrite, CollisionRename, CollisionSkip, CollisionAsk:
+		return policy
+	default:
+		return CollisionRename
+	}
+}
+
+// collisionPolicy retourne la politique de la requête ou, à défaut, la politique globale
+func (d *Downloader) collisionPolicy(req Request) CollisionPolicy {
+	if req.CollisionPolicy != "" {
+		return req.CollisionPolicy
+	}
+	return ParseCollisionPolicy(string(d.CollisionPolicy))
+}
+
+// createOutput choisit le fichier de destination selon la politique de collision,
+// crée son fichier temporaire et retourne celui-ci avec le chemin final effectif.
+// errSkipped est retourné, avec le chemin du fichier conservé, si le
+// téléchargement est inutile.
+func (d *Downloader) createOutput(ctx context.Context, req Request, filePath string, resp *http.Response) (*os.File, string, error) {
+	out, err := createPart(filePath)
+	if err == nil || !errors.Is(err, os.ErrExist) {
+		return out, filePath, err
+	}
+
+	policy := d.collisionPolicy(req)
+	if policy == CollisionAsk {
+		policy = CollisionRename
+		if d.OnCollision != nil {
+			if policy, err = d.OnCollision(ctx, req.ID, filePath); err != nil {
+				return nil, "", err
+			}
+			// L'utilisateur a choisi de garder son fichier, identique ou non
+			if policy == CollisionSkip {
+				return nil, filePath, errSkipped
+			}
+		}
+	}
+
+	switch policy {
+	case CollisionOverwrite:
+		// Le fichier existant n'est remplacé qu'au renommage final ; un fichier
+		// temporaire déjà présent appartient à un autre téléchargement
+		out, err := os.OpenFile(partPath(filePath), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil || !errors.Is(err, os.ErrExist) {
+			return out, filePath, err
+		}
+	case CollisionSkip:
+		if sameFile(filePath, resp) {
+			return nil, filePath, errSkipped
+		}
+	}
+
+	return createRenamed(filePath)
+}
+
+// createRenamed crée le fichier temporaire du premier "nom (n).ext" disponible ;
+// O_EXCL garantit que deux téléchargements simultanés n'obtiennent pas le même nom
+func createRenamed(filePath string) (*os.File, string, error) {
+	ext := filepath.Ext(filePath)
+	base := strings.TrimSuffix(filePath, ext)
+
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		out, err := createPart(candidate)
+		if err == nil {
+			return out, candidate, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, "", err
+		}
+	}
+
+	return nil, "", fmt.Errorf("aucun nom disponible pour %s", filePath)
+}
+
+// sameFile indique si le fichier local correspond au fichier distant. Seule la
+// taille est comparée, sauf si une réponse complète (200) fournit Content-MD5 :
+// dans la réponse 206 de la sonde "bytes=0-0", l'en-tête ne décrit qu'un octet.
+func sameFile(filePath string, resp *http.Response) bool {
+	info, err := os.Stat(filePath)
+	if err != nil || resp.ContentLength < 0 || info.Size() != resp.ContentLength {
+		return false
+	}
+
+	expected := resp.Header.Get("Content-MD5")
+	if expected == "" || resp.StatusCode != http.StatusOK {
+		return true
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return false
+	}
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil)) == expected
+}
@@ -0,0 +1,224 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultMinFreeSpace est l'espace libre en dessous duquel les téléchargements
+// sont mis en pause tant qu'aucun paramètre n'est enregistré
+const DefaultMinFreeSpace = 512 << 20
+
+// Intervalle entre deux vérifications de l'espace disque
+const diskCheckInterval = 5 * time.Second
+
+// ErrInsufficientSpace signale que le disque de destination est plein ou ne peut
+// pas accueillir le fichier
+var ErrInsufficientSpace = errors.New("espace disque insuffisant")
+
+// SpaceError détaille un manque d'espace ; Required est nul lorsque le disque
+// s'est rempli pendant l'écriture
+type SpaceError struct {
+	Dir       string
+	Required  int64
+	Available int64
+}
+
+func (e *SpaceError) Error() string {
+	if e.Required > 0 {
+		return fmt.Sprintf("%v dans %s : %d octets nécessaires, %d disponibles", ErrInsufficientSpace, e.Dir, e.Required, e.Available)
+	}
+	return fmt.Sprintf("%v dans %s", ErrInsufficientSpace, e.Dir)
+}
+
+func (e *SpaceError) Is(target error) bool {
+	return target == ErrInsufficientSpace
+}
+
+// existingDir remonte jusqu'au premier répertoire existant, dont l'espace libre
+// est celui où sera créé dir
+func existingDir(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// checkFreeSpace vérifie que required octets peuvent être écrits dans dir ; faute
+// de pouvoir mesurer l'espace libre, le téléchargement est tenté
+func checkFreeSpace(dir string, required int64) error {
+	if required <= 0 {
+		return nil
+	}
+	available, err := freeSpace(existingDir(dir))
+	if err != nil {
+		return nil
+	}
+	if available < required {
+		return &SpaceError{Dir: dir, Required: required, Available: available}
+	}
+	return nil
+}
+
+// noSpaceError remplace une erreur d'écriture due à un disque plein par une
+// SpaceError, et retourne nil pour toute autre erreur
+func noSpaceError(dir string, err error) error {
+	if !isNoSpace(err) {
+		return nil
+	}
+	available, _ := freeSpace(existingDir(dir))
+	return &SpaceError{Dir: dir, Available: available}
+}
+
+// SetMinFreeSpace modifie l'espace libre en dessous duquel tous les
+// téléchargements sont mis en pause ; 0 désactive la surveillance
+func (d *Downloader) SetMinFreeSpace(bytes int64) {
+	d.minFreeSpace.Store(max(bytes, 0))
+}
+
+// MinFreeSpace retourne le seuil de mise en pause, en octets
+func (d *Downloader) MinFreeSpace() int64 {
+	return d.minFreeSpace.Load()
+}
+
+// diskWatch retient, d'une vérification de l'espace disque à l'autre, les
+// téléchargements mis en pause faute d'espace
+type diskWatch struct {
+	paused []int64
+	low    bool
+}
+
+// watchDiskSpace vérifie périodiquement l'espace libre du dossier de destination
+func (d *Downloader) watchDiskSpace() {
+	ticker := time.NewTicker(diskCheckInterval)
+	defer ticker.Stop()
+
+	var watch diskWatch
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		available, err := freeSpace(existingDir(d.DownloadDir))
+		if err != nil {
+			continue
+		}
+		d.checkDiskSpace(&watch, available)
+	}
+}
+
+// checkDiskSpace met en pause tous les téléchargements lorsque l'espace libre
+// passe sous le seuil, et reprend ceux qu'elle a mis en pause lorsqu'il redevient
+// suffisant, sauf ceux que l'utilisateur a mis en pause, repris, annulés ou
+// supprimés entre-temps
+func (d *Downloader) checkDiskSpace(w *diskWatch, available int64) {
+	threshold := d.minFreeSpace.Load()
+	switch {
+	case threshold > 0 && available < threshold:
+		// Mettre aussi en pause les téléchargements ajoutés depuis la dernière vérification
+		w.paused = append(w.paused, d.pauseAll()...)
+		// Sans téléchargement interrompu, il n'y a rien à signaler ni à reprendre
+		if !w.low && len(w.paused) > 0 {
+			w.low = true
+			log.Printf("Espace disque insuffisant dans %s : %d octets disponibles, téléchargements mis en pause", d.DownloadDir, available)
+			d.emit(Event{Type: EventLowDiskSpace, IDs: w.paused, Available: available})
+		}
+	case w.low && (threshold <= 0 || available >= threshold+threshold/4):
+		// La marge évite d'alterner pause et reprise autour du seuil
+		w.low = false
+		resumed := d.resumeAll(w.paused)
+		w.paused = nil
+		log.Printf("Espace disque de nouveau suffisant dans %s : %d octets disponibles", d.DownloadDir, available)
+		if len(resumed) > 0 {
+			d.emit(Event{Type: EventDiskSpaceRecovered, IDs: resumed, Available: available})
+		}
+	}
+}
+
+// pauseAll met en pause tous les téléchargements actifs et retourne les IDs de
+// ceux qui ont été interrompus, dans l'ordre où les reprendre : ceux en cours,
+// puis ceux de la file d'attente
+func (d *Downloader) pauseAll() []int64 {
+	queued := d.QueueOrder()
+	isQueued := make(map[int64]bool, len(queued))
+	for _, id := range queued {
+		isQueued[id] = true
+	}
+	var running []int64
+	d.jobs.Range(func(key, _ any) bool {
+		if id := key.(int64); !isQueued[id] {
+			running = append(running, id)
+		}
+		return true
+	})
+
+	// Les téléchargements en attente d'abord, pour qu'aucun ne prenne une place libérée
+	paused := make(map[int64]bool)
+	for _, id := range append(queued, running...) {
+		if _, ok := d.jobs.Load(id); !ok {
+			continue
+		}
+		ok, err := d.pauseForLowDisk(id)
+		if err != nil {
+			log.Printf("Erreur lors de la mise en pause du téléchargement %d : %v", id, err)
+			continue
+		}
+		// Un téléchargement terminé avant la pause ne doit pas être repris
+		paused[id] = ok
+	}
+
+	var ids []int64
+	for _, id := range append(running, queued...) {
+		if paused[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// pauseForLowDisk met le téléchargement en pause et le retient pour la reprise,
+// sauf s'il s'est terminé avant que la pause ne prenne effet
+func (d *Downloader) pauseForLowDisk(id int64) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	paused, err := d.pauseLocked(id)
+	if paused {
+		d.diskPaused[id] = true
+	}
+	return paused, err
+}
+
+// resumeAll reprend, dans l'ordre de ids, les téléchargements mis en pause faute
+// d'espace auxquels l'utilisateur n'a pas touché depuis, et retourne ceux qui
+// ont pu l'être
+func (d *Downloader) resumeAll(ids []int64) []int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var resumed []int64
+	for _, id := range ids {
+		if !d.diskPaused[id] {
+			continue
+		}
+		delete(d.diskPaused, id)
+		if err := d.resumeLocked(id); err != nil {
+			log.Printf("Erreur lors de la reprise du téléchargement %d : %v", id, err)
+			continue
+		}
+		resumed = append(resumed, id)
+	}
+	return resumed
+}
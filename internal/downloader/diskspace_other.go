@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package downloader
+
+import "errors"
+
+// freeSpace n'est pas disponible sur ce système : les vérifications d'espace
+// disque sont ignorées
+func freeSpace(dir string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+// isNoSpace ne reconnaît pas les erreurs de disque plein sur ce système : elles
+// restent des erreurs d'écriture ordinaires
+func isNoSpace(err error) bool {
+	return false
+}
@@ -0,0 +1,97 @@
+package downloader
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// Les téléchargements sont mis en pause sous le seuil et repris seulement une
+// fois l'espace libre remonté au-dessus du seuil augmenté de la marge
+func TestCheckDiskSpaceHysteresis(t *testing.T) {
+	server := startRangeServer(t)
+	release := server.hold()
+	defer release()
+	d := newTestDownloader(t)
+	d.Store = newMemoryStore()
+	d.SetMinFreeSpace(1000)
+	sub := d.Subscribe(DefaultEventBuffer)
+	defer sub.Close()
+
+	req, err := d.Add(server.URL + "/file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go d.DownloadContext(context.Background(), req)
+	waitEvent(t, sub, req.ID, EventStarted)
+
+	var watch diskWatch
+	d.checkDiskSpace(&watch, 2000)
+	if watch.low {
+		t.Fatal("espace suffisant signalé comme insuffisant")
+	}
+
+	d.checkDiskSpace(&watch, 500)
+	event := waitEvent(t, sub, 0, EventLowDiskSpace)
+	if !slices.Equal(event.IDs, []int64{req.ID}) || event.Available != 500 {
+		t.Errorf("événement %+v, attendu la mise en pause du téléchargement %d", event, req.ID)
+	}
+	if status, _ := d.status(req.ID); status != StatusPaused {
+		t.Fatalf("statut %q sous le seuil, attendu %q", status, StatusPaused)
+	}
+
+	// Au-dessus du seuil mais dans la marge : toujours en pause
+	d.checkDiskSpace(&watch, 1100)
+	if status, _ := d.status(req.ID); !watch.low || status != StatusPaused {
+		t.Fatalf("statut %q dans la marge, attendu %q", status, StatusPaused)
+	}
+
+	release()
+	d.checkDiskSpace(&watch, 1300)
+	event = waitEvent(t, sub, 0, EventDiskSpaceRecovered)
+	if !slices.Equal(event.IDs, []int64{req.ID}) {
+		t.Errorf("événement %+v, attendu la reprise du téléchargement %d", event, req.ID)
+	}
+	waitEvent(t, sub, req.ID, EventCompleted)
+}
+
+// Un téléchargement que l'utilisateur a repris pendant le manque d'espace n'est
+// pas repris une seconde fois
+func TestCheckDiskSpaceSkipsUserResumed(t *testing.T) {
+	server := startRangeServer(t)
+	release := server.hold()
+	defer release()
+	d := newTestDownloader(t)
+	d.Store = newMemoryStore()
+	d.SetMinFreeSpace(1000)
+	sub := d.Subscribe(DefaultEventBuffer)
+	defer sub.Close()
+
+	req, err := d.Add(server.URL + "/file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go d.DownloadContext(context.Background(), req)
+	waitEvent(t, sub, req.ID, EventStarted)
+
+	var watch diskWatch
+	d.checkDiskSpace(&watch, 500)
+	waitEvent(t, sub, 0, EventLowDiskSpace)
+	if err := d.ResumeDownload(req.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, sub, req.ID, EventStarted)
+
+	d.checkDiskSpace(&watch, 2000)
+	if watch.low || len(watch.paused) != 0 {
+		t.Errorf("surveillance %+v après le retour de l'espace", watch)
+	}
+	// Une reprise par la surveillance aurait été publiée avant le retour de checkDiskSpace
+	for len(sub.Events()) > 0 {
+		if event := <-sub.Events(); event.Type == EventDiskSpaceRecovered {
+			t.Errorf("événement %+v pour un téléchargement repris par l'utilisateur", event)
+		}
+	}
+	release()
+	waitEvent(t, sub, req.ID, EventCompleted)
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin || freebsd
+
+package downloader
+
+import (
+	"errors"
+	"syscall"
+)
+
+// freeSpace retourne l'espace disponible pour un utilisateur non privilégié
+// sur le système de fichiers contenant dir
+func freeSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), nil
+}
+
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
@@ -1,51 +1,94 @@
 package downloader
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type ProgressCallback func(url string, progress float64)
-
 type Downloader struct {
 	DownloadDir      string
-	MaxConcurrent    int // Rendu exporté
-	MaxChunks        int // Ajoutez cette ligne
-	semaphore        chan struct{}
-	progressCallback ProgressCallback
-	pausedDownloads  sync.Map
-	cancelDownloads  sync.Map
-	activeDownloads  sync.Map   // Ajoutez cette ligne
-	mu               sync.Mutex // Ajoutez cette ligne si elle n'existe pas déjà
-	OnDownloadAdded  func(url string, totalSize int64) error
-	OnPause          func(url string) error
-	OnResume         func(url string) error
-	OnComplete       func(url string) error
-	OnDeleted        func(url string, deleteFile bool) error
-	OnCancel         func(url string) error
-	OnUpdate         func(url string, progress float64)
-	OnError          func(url string, err error)
+	MaxConcurrent    int             // Rendu exporté
+	MaxChunks        int             // Ajoutez cette ligne
+	CollisionPolicy  CollisionPolicy // Politique appliquée aux requêtes qui n'en précisent pas
+	Store            Store           // Enregistrement des téléchargements, facultatif
+	limiter          *RateLimiter    // Débit maximal cumulé de tous les téléchargements
+	queue            *downloadQueue
+	hostLimits       hostLimits                  // Limites de charge par hôte
+	connections      *hostConnections            // Connexions ouvertes par hôte
+	credentials      hostCredentials             // Identifiants enregistrés par hôte
+	proxies          proxySettings               // Proxy global et règles par hôte
+	tls              tlsSettings                 // Autorités, certificats clients et épinglage
+	transport        *proxyTransport             // Transports conservés par proxy
+	client           *http.Client                // Client partagé par les téléchargements
+	minFreeSpace     atomic.Int64                // Espace libre en dessous duquel tout est mis en pause
+	retryPolicy      atomic.Pointer[RetryPolicy] // Relue par chaque job à son démarrage
+	nextID           atomic.Int64                // Dernier ID attribué en l'absence de Store
+	eventSubscribers eventBus
+	jobs             sync.Map // Téléchargements en cours, indexés par ID
+	statuses         sync.Map // Dernier statut publié de chaque téléchargement
+	ctx              context.Context
+	stop             context.CancelCauseFunc
+	wg               sync.WaitGroup
+	mu               sync.Mutex     // Ajoutez cette ligne si elle n'existe pas déjà
+	diskPaused       map[int64]bool // Mis en pause faute d'espace, à reprendre ; protégé par mu
+	// Choix de l'utilisateur lorsque le fichier de destination existe déjà ;
+	// ctx est annulé si le téléchargement s'interrompt avant la réponse
+	OnCollision func(ctx context.Context, id int64, filePath string) (CollisionPolicy, error)
+}
+
+// Request identifie un téléchargement : l'ID est attribué par le Store,
+// deux requêtes sur la même URL sont donc deux téléchargements indépendants
+type Request struct {
+	ID              int64
+	URL             string
+	CollisionPolicy CollisionPolicy // Vide : politique globale du Downloader
+	SpeedLimit      int64           // Débit maximal en octets par seconde, 0 si illimité
+	Checksum        string          // Empreinte attendue ("sha256:<hex>") ou URL d'un fichier d'empreintes
+	Priority        Priority
+	Options         HTTPOptions
+	Credentials     Credentials // Identifiants propres au téléchargement, envoyés au seul hôte de l'URL
 }
 
 type Download struct {
 	ID             int64
 	URL            string
-	Status         string
+	Status         Status
 	Size           int64
 	DownloadedSize int64
+	FileName       string
 	SavePath       string
+	Retries        int
+	SpeedLimit     int64
+	Priority       Priority
 	Chunks         []ChunkInfo
+	ETag           string
+	LastModified   string
+	CreatedAt      time.Time // Zéro si inconnu, comme StartedAt et FinishedAt
+	StartedAt      time.Time // Premier démarrage du transfert
+	FinishedAt     time.Time // Fin du dernier transfert : terminé, échoué ou annulé
+	LastError      string
 }
 
+// ChunkInfo décrit un segment du fichier, de l'offset Start inclus à End exclu ;
+// un segment peut être raccourci pendant le téléchargement lorsqu'un autre worker
+// en reprend la fin
 type ChunkInfo struct {
-	ID       int
-	Size     int64
-	Progress float64
+	ID         int
+	Start      int64
+	End        int64
+	Size       int64 // End - Start
+	Downloaded int64
+	Progress   float64
 }
 
 func NewDownloader(maxChunks int) *Downloader {
@@ -55,251 +98,547 @@ func NewDownloader(maxChunks int) *Downloader {
 	}
 	downloadDir := filepath.Join(homeDir, "Downloads")
 	maxConcurrent := 5 // Nombre maximum de téléchargements simultanés
-
-	return &Downloader{
-		DownloadDir:      downloadDir,
-		MaxConcurrent:    maxConcurrent,
-		MaxChunks:        maxChunks, // Initialisez MaxChunks
-		semaphore:        make(chan struct{}, maxConcurrent),
-		progressCallback: func(url string, progress float64) {},
-		pausedDownloads:  sync.Map{},
-		cancelDownloads:  sync.Map{},
-		activeDownloads:  sync.Map{}, // Ajoutez cette ligne
+	ctx, stop := context.WithCancelCause(context.Background())
+
+	d := &Downloader{
+		DownloadDir:     downloadDir,
+		MaxConcurrent:   maxConcurrent,
+		MaxChunks:       maxChunks, // Initialisez MaxChunks
+		CollisionPolicy: CollisionRename,
+		limiter:         NewRateLimiter(0),
+		queue:           newDownloadQueue(maxConcurrent),
+		jobs:            sync.Map{},
+		diskPaused:      make(map[int64]bool),
+		ctx:             ctx,
+		stop:            stop,
+	}
+	d.hostLimits.set(DefaultHostLimit(), nil)
+	d.proxies.set(nil, true, nil)
+	d.tls.set(&tlsPolicy{})
+	d.transport = newProxyTransport(&d.proxies, &d.tls)
+	d.client = &http.Client{Transport: d.transport}
+	d.connections = newHostConnections(func(host string) int {
+		return d.hostLimits.forHost(host).MaxConnections
+	})
+	d.queue.hostLimit = func(host string) int {
+		return d.hostLimits.forHost(host).MaxDownloads
 	}
+	d.queue.onChange = func(order []int64) {
+		d.emit(Event{Type: EventQueueChanged, IDs: order})
+	}
+	d.minFreeSpace.Store(DefaultMinFreeSpace)
+	d.SetRetryPolicy(DefaultRetryPolicy())
+	go d.watchDiskSpace()
+	return d
 }
 
-func (d *Downloader) SetProgressCallback(callback ProgressCallback) {
-	d.progressCallback = callback
+// Add enregistre un nouveau téléchargement et retourne la requête portant son ID ;
+// les identifiants présents dans l'URL sont retirés de celle enregistrée et
+// transmis dans la requête
+func (d *Downloader) Add(url string) (Request, error) {
+	url, creds := splitUserinfo(url)
+	id, err := d.addToStore(url)
+	if err != nil {
+		return Request{}, fmt.Errorf("impossible d'ajouter le téléchargement : %v", err)
+	}
+	d.emit(Event{Type: EventAdded, ID: id, URL: url})
+	return Request{ID: id, URL: url, Credentials: creds}, nil
 }
 
 func (d *Downloader) Download(url string) error {
-	d.semaphore <- struct{}{}        // Acquérir une place dans le sémaphore
-	defer func() { <-d.semaphore }() // Libérer la place à la fin
+	return d.DownloadContext(context.Background(), Request{URL: url})
+}
 
-	d.activeDownloads.Store(url, struct{}{})
-	defer d.activeDownloads.Delete(url)
+// DownloadContext télécharge req jusqu'à ce que ctx soit annulé ou expire ; les
+// requêtes HTTP et l'écriture du fichier s'arrêtent alors et l'erreur retournée
+// est la cause de l'annulation. Une requête sans ID est d'abord enregistrée via Add.
+func (d *Downloader) DownloadContext(ctx context.Context, req Request) error {
+	if req.ID == 0 {
+		var err error
+		if req, err = d.register(req); err != nil {
+			return err
+		}
+	}
 
-	// Envoyer une requête GET pour obtenir la taille du fichier
-	resp, err := http.Head(url)
+	ctx, j, err := d.enqueue(ctx, req)
 	if err != nil {
-		return fmt.Errorf("erreur lors de la récupération des informations du fichier : %v", err)
+		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("mauvaise réponse du serveur : %s", resp.Status)
+	return d.download(ctx, j, false)
+}
+
+// register enregistre une requête sans ID via Add : elle reprend l'URL débarrassée
+// de ses identifiants et, faute d'identifiants explicites, ceux qu'elle contenait
+func (d *Downloader) register(req Request) (Request, error) {
+	added, err := d.Add(req.URL)
+	if err != nil {
+		return req, err
 	}
-	totalSize := resp.ContentLength
+	req.ID, req.URL = added.ID, added.URL
+	if req.Credentials.IsZero() {
+		req.Credentials = added.Credentials
+	}
+	return req, nil
+}
 
-	// Ajouter le téléchargement à la base de données
-	err = d.OnDownloadAdded(url, totalSize)
+// enqueue démarre le job de req et le place dans la file d'attente. Il est appelé
+// avant de lancer la goroutine du téléchargement pour que la file respecte
+// l'ordre des requêtes.
+func (d *Downloader) enqueue(ctx context.Context, req Request) (context.Context, *job, error) {
+	ctx, j, err := d.startJob(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	j.entry = d.queue.push(j.ID, j.host, j.Priority)
+	return ctx, j, nil
+}
+
+// download télécharge le job placé dans la file par enqueue ; si resume est vrai,
+// l'état persisté est utilisé pour reprendre là où le téléchargement s'était arrêté
+func (d *Downloader) download(ctx context.Context, j *job, resume bool) (err error) {
+	defer func() { d.finishJob(j, err) }()
+	req := j.Request
+
+	// Attendre une place dans la file, sauf si le téléchargement est annulé entre-temps
+	if err := d.queue.wait(ctx, j.entry); err != nil {
+		return err
+	}
+	defer d.queue.release(j.entry) // Libérer la place à la fin
+	d.emit(Event{Type: EventStarted, ID: req.ID})
+
+	var state *ResumeState
+	if resume {
+		state, err = d.loadState(req.ID)
+		if err != nil {
+			return fmt.Errorf("impossible de charger l'état du téléchargement : %v", err)
+		}
+	}
+	// Sans avancement en base, celui écrit à côté du fichier temporaire permet la reprise
+	if state != nil && len(state.Chunks) == 0 && state.FilePath != "" {
+		if sidecar, err := readSidecar(state.FilePath); err != nil {
+			log.Printf("État de reprise illisible pour le téléchargement %d : %v", req.ID, err)
+		} else if sidecar != nil && sidecar.URL == req.URL {
+			state.Chunks = sidecar.Chunks
+			state.Size = sidecar.Size
+			state.ETag = sidecar.ETag
+			state.LastModified = sidecar.LastModified
+		}
+	}
+	if state != nil {
+		j.retries.Store(int64(state.Retries))
+		j.limiter.SetRate(state.SpeedLimit)
+		if j.Checksum == "" {
+			j.Checksum = state.Checksum
+		}
+	}
+
+	// Envoyer une requête HEAD pour obtenir la taille du fichier
+	var resp *http.Response
+	err = d.withRetry(ctx, j, func() error {
+		release, err := d.connections.acquire(ctx, j.host)
+		if err != nil {
+			return err
+		}
+		defer release()
+		resp, err = d.probe(ctx, j)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	totalSize := resp.ContentLength
 
 	// Créer le répertoire de téléchargement s'il n'existe pas
 	if err := os.MkdirAll(d.DownloadDir, os.ModePerm); err != nil {
 		return fmt.Errorf("impossible de créer le répertoire de téléchargement : %v", err)
 	}
 
-	// Obtenir le nom du fichier à partir de l'URL
-	fileName := filepath.Base(url)
-
-	// Créer le fichier de destination
+	// Déterminer le nom du fichier à partir de la réponse du serveur
+	fileName := resolveFileName(resp)
 	filePath := filepath.Join(d.DownloadDir, fileName)
-	out, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("impossible de créer le fichier : %v", err)
+	if state != nil && state.FilePath != "" {
+		filePath = state.FilePath
 	}
-	defer out.Close()
 
-	// Envoyer une requête GET pour télécharger le fichier
-	resp, err = http.Get(url)
+	// Connaître l'empreinte attendue avant de télécharger, pour échouer au plus tôt
+	expected, err := d.expectedChecksum(ctx, j, resp, fileName)
 	if err != nil {
-		return fmt.Errorf("erreur lors du téléchargement : %v", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	// Vérifier le code de statut de la réponse
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("mauvaise réponse du serveur : %s", resp.Status)
+	// Le téléchargement segmenté n'est possible que si le serveur accepte les requêtes Range
+	ranged := supportsRanges(resp) && totalSize > 0
+	chunkCount := d.chunkCount(j.host)
+	if !ranged {
+		chunkCount = 1
+	}
+	progress := newDownloadProgress(totalSize, splitChunks(totalSize, chunkCount))
+	j.progress.Store(progress)
+	progress.filePath = filePath
+	progress.etag = resp.Header.Get("ETag")
+	progress.lastModified = resp.Header.Get("Last-Modified")
+
+	// Reprendre à partir des offsets enregistrés si le fichier distant n'a pas changé
+	resuming := ranged && state.canResume(resp, filePath)
+	if resuming {
+		progress = newDownloadProgress(totalSize, state.Chunks)
+		j.progress.Store(progress)
+		progress.filePath = filePath
+		progress.etag = state.ETag
+		progress.lastModified = state.LastModified
 	}
 
-	// Créer un canal pour annuler le téléchargement
-	cancelChan := make(chan struct{})
-	d.cancelDownloads.Store(url, cancelChan)
+	// Refuser d'emblée un fichier qui ne tiendra pas sur le disque
+	if totalSize > 0 {
+		if err := checkFreeSpace(filepath.Dir(filePath), totalSize-progress.downloaded); err != nil {
+			return err
+		}
+	}
 
-	// Initialiser les chunks
-	chunkSize := totalSize / int64(d.MaxChunks)
-	chunks := make([]ChunkInfo, d.MaxChunks)
-	for i := 0; i < d.MaxChunks; i++ {
-		chunks[i] = ChunkInfo{
-			ID:       i + 1,
-			Size:     chunkSize,
-			Progress: 0,
+	var out *os.File
+	if resuming {
+		out, err = os.OpenFile(partPath(filePath), os.O_RDWR, 0)
+	} else if state != nil && state.FilePath != "" {
+		// Redémarrage complet : le fichier temporaire appartient déjà à ce téléchargement
+		out, err = os.Create(partPath(filePath))
+		if err == nil {
+			err = preallocateFile(out, totalSize)
+		}
+	} else {
+		out, filePath, err = d.createOutput(ctx, req, filePath, resp)
+		progress.filePath = filePath
+		if err == errSkipped {
+			// Le fichier existant est conservé : le téléchargement est considéré comme terminé
+			progress.markComplete()
+			progress.finalize()
+			d.saveState(j, progress)
+			d.emitProgress(EventCompleted, j)
+			return nil
+		}
+		if err == nil {
+			err = preallocateFile(out, totalSize)
 		}
 	}
-	// Ajuster la taille du dernier chunk
-	chunks[d.MaxChunks-1].Size = totalSize - chunkSize*int64(d.MaxChunks-1)
-
-	// Créer un lecteur qui rapporte la progression
-	reader := &ProgressReader{
-		Reader: resp.Body,
-		Total:  totalSize,
-		OnProgress: func(progress float64) {
-			d.progressCallback(url, progress)
-		},
-	}
-
-	// Copier le contenu du fichier
-	downloaded := int64(0)
-	for {
-		select {
-		case <-cancelChan:
-			return fmt.Errorf("téléchargement annulé")
-		default:
-			if _, isPaused := d.pausedDownloads.Load(url); isPaused {
-				time.Sleep(time.Second)
-				continue
-			}
+	if err != nil {
+		if out != nil {
+			// Fichier tout juste créé mais inutilisable : ne pas laisser de fichier temporaire orphelin
+			out.Close()
+			os.Remove(out.Name())
+		}
+		if ctx.Err() != nil {
+			// Interrompu pendant la question sur le fichier existant
+			return context.Cause(ctx)
+		}
+		if err := noSpaceError(filepath.Dir(filePath), err); err != nil {
+			return err
+		}
+		return fmt.Errorf("impossible de créer le fichier : %v", err)
+	}
+	defer out.Close()
 
-			n, err := io.CopyN(out, reader, 32*1024) // Copier par blocs de 32KB
-			downloaded += n
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return fmt.Errorf("erreur lors de l'écriture du fichier : %v", err)
-			}
+	// Enregistrer l'avancement quelle que soit l'issue (pause, annulation, erreur)
+	defer d.saveState(j, progress)
 
-			// Mettre à jour la progression
-			progress := float64(downloaded) / float64(totalSize)
-			d.progressCallback(url, progress)
-
-			// Mettre à jour la progression des chunks
-			for i := range chunks {
-				if downloaded >= chunks[i].Size {
-					chunks[i].Progress = 1
-				} else {
-					chunks[i].Progress = float64(downloaded) / float64(chunks[i].Size)
-					break
-				}
-			}
+	if ranged {
+		err = d.downloadChunks(ctx, j, out, progress)
+	} else {
+		err = d.downloadStream(ctx, j, out, progress)
+	}
+	if err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		if err := noSpaceError(filepath.Dir(filePath), err); err != nil {
+			return err
 		}
+		return fmt.Errorf("erreur lors de l'écriture du fichier : %v", err)
+	}
 
-		if downloaded >= totalSize {
-			break
+	// Vérifier l'intégrité du fichier avant de le déclarer terminé
+	if expected != nil {
+		if err := verifyChecksum(partPath(filePath), *expected); err != nil {
+			if errors.Is(err, ErrCorrupted) {
+				// Une reprise doit retélécharger le fichier en entier
+				progress.reset()
+			}
+			return err
 		}
 	}
 
-	// Mettre à jour le statut du téléchargement dans la base de données
-	d.OnComplete(url)
+	// Le fichier n'apparaît sous son nom définitif qu'une fois complet et vérifié
+	if err := finalizePart(filePath); err != nil {
+		return err
+	}
+	progress.finalize()
 
-	d.progressCallback(url, 1.0) // Indiquer que le téléchargement est terminé
+	d.emitProgress(EventCompleted, j)
 	return nil
 }
 
-type ProgressReader struct {
-	io.Reader
-	Total      int64
-	OnProgress func(float64)
-	read       int64
+// probe interroge le serveur pour connaître la taille du fichier et le support
+// des requêtes Range : d'abord avec une requête HEAD puis, si elle est refusée
+// (403, 405 de nombreux CDN) ou n'indique pas la taille, avec une requête GET
+// limitée au premier octet. Une taille inconnue est signalée par un
+// ContentLength négatif.
+func (d *Downloader) probe(ctx context.Context, j *job) (*http.Response, error) {
+	head, err := d.probeRequest(ctx, j, http.MethodHead)
+	if err != nil {
+		return nil, err
+	}
+	if head.StatusCode == http.StatusOK && head.ContentLength >= 0 {
+		return head, nil
+	}
+
+	resp, err := d.probeRequest(ctx, j, http.MethodGet)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent,
+		// Un fichier vide n'a pas de premier octet : "Content-Range: bytes */0"
+		resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && strings.HasSuffix(resp.Header.Get("Content-Range"), "/0"):
+		return rangeProbeResponse(resp), nil
+	case resp.StatusCode == http.StatusOK:
+		// Le serveur ignore l'en-tête Range : seul le téléchargement complet est possible
+		return resp, nil
+	case head.StatusCode == http.StatusOK:
+		// La réponse à HEAD reste exploitable, sans la taille du fichier
+		return head, nil
+	default:
+		return nil, responseError(resp)
+	}
+}
+
+// probeRequest envoie une requête d'information ; une requête GET ne demande que
+// le premier octet du fichier, le corps de la réponse est fermé sans être lu
+func (d *Downloader) probeRequest(ctx context.Context, j *job, method string) (*http.Response, error) {
+	req, err := d.newRequest(ctx, j, method, j.URL)
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	// Demander l'empreinte du fichier dans l'en-tête Digest (RFC 3230)
+	req.Header.Set("Want-Digest", "SHA-512;q=1, SHA-256;q=0.9, SHA;q=0.3, MD5;q=0.1")
+	resp, err := j.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		err = fmt.Errorf("erreur lors de la récupération des informations du fichier : %w", err)
+		if errors.Is(err, ErrCertificatePin) {
+			// La clé présentée par le serveur ne changera pas d'une tentative à l'autre
+			return nil, err
+		}
+		return nil, retryable(err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// rangeProbeResponse présente la réponse à une requête "bytes=0-0" comme la
+// réponse à HEAD qu'elle remplace : taille complète tirée de Content-Range,
+// requêtes Range acceptées
+func rangeProbeResponse(resp *http.Response) *http.Response {
+	resp.ContentLength = -1
+	if _, total, found := strings.Cut(resp.Header.Get("Content-Range"), "/"); found {
+		if size, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64); err == nil && size >= 0 {
+			resp.ContentLength = size
+		}
+	}
+	resp.Header.Set("Accept-Ranges", "bytes")
+	return resp
 }
 
-func (pr *ProgressReader) Read(p []byte) (int, error) {
-	n, err := pr.Reader.Read(p)
-	pr.read += int64(n)
-	if pr.OnProgress != nil {
-		progress := float64(pr.read) / float64(pr.Total)
-		pr.OnProgress(progress)
+// ProgressUnknown est transmis comme progression lorsque la taille du fichier
+// n'est pas connue : l'interface affiche alors une progression indéterminée
+const ProgressUnknown = -1
+
+// progressRatio retourne la part téléchargée, bornée à 1, ou ProgressUnknown
+func progressRatio(downloaded, total int64) float64 {
+	if total <= 0 {
+		return ProgressUnknown
 	}
-	return n, err
+	return min(float64(downloaded)/float64(total), 1)
+}
+
+func (d *Downloader) DownloadMultiple(reqs []Request) []error {
+	return d.DownloadMultipleContext(context.Background(), reqs)
 }
 
-func (d *Downloader) DownloadMultiple(urls []string) []error {
+// DownloadMultipleContext télécharge les requêtes en parallèle ; annuler ctx les interrompt toutes
+func (d *Downloader) DownloadMultipleContext(ctx context.Context, reqs []Request) []error {
 	var wg sync.WaitGroup
-	errors := make([]error, len(urls))
+	errors := make([]error, len(reqs))
+
+	for i, req := range reqs {
+		if req.ID == 0 {
+			var err error
+			if req, err = d.register(req); err != nil {
+				errors[i] = err
+				continue
+			}
+		}
+
+		// Placer les requêtes dans la file dans l'ordre reçu, avant de lancer les goroutines
+		jobCtx, j, err := d.enqueue(ctx, req)
+		if err != nil {
+			errors[i] = err
+			continue
+		}
 
-	for i, url := range urls {
 		wg.Add(1)
-		go func(i int, url string) {
+		go func(i int) {
 			defer wg.Done()
-			errors[i] = d.Download(url)
-		}(i, url)
+			errors[i] = d.download(jobCtx, j, false)
+		}(i)
 	}
 
 	wg.Wait()
 	return errors
 }
 
-func (d *Downloader) ResumePendingDownloads(pendingDownload []string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	urls := make([]string, len(pendingDownload))
-	for i, download := range pendingDownload {
-		urls[i] = download
+func (d *Downloader) ResumePendingDownloads(pendingDownload []int64) error {
+	var wg sync.WaitGroup
+	for _, id := range pendingDownload {
+		ctx, j, err := d.enqueueResume(context.Background(), id)
+		if err != nil {
+			if !IsInterrupted(err) && !errors.Is(err, ErrAlreadyActive) {
+				d.emit(Event{Type: EventFailed, ID: id, Err: err})
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Un échec est publié par finishJob
+			d.download(ctx, j, true)
+		}()
 	}
 
-	d.DownloadMultiple(urls)
+	wg.Wait()
 	return nil
 }
 
-// Ajoutez cette méthode
-func (d *Downloader) UpdateSemaphore() {
-	d.semaphore = make(chan struct{}, d.MaxConcurrent)
+// enqueueResume replace dans la file un téléchargement enregistré, avec sa priorité
+func (d *Downloader) enqueueResume(ctx context.Context, id int64) (context.Context, *job, error) {
+	state, err := d.loadState(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("impossible de charger l'état du téléchargement : %v", err)
+	}
+	if state == nil {
+		return nil, nil, fmt.Errorf("aucun téléchargement trouvé pour l'ID : %d", id)
+	}
+
+	return d.enqueue(ctx, Request{ID: id, URL: state.URL, Checksum: state.Checksum, Priority: state.Priority, Options: state.Options, Credentials: state.Credentials})
 }
 
 // Ajoutez cette nouvelle méthode
 
-func (d *Downloader) DeleteDownload(url string, deleteFile bool) error {
+func (d *Downloader) DeleteDownload(id int64, deleteFile bool) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Arrêter le téléchargement s'il est en cours
-	if cancel, exists := d.cancelDownloads.Load(url); exists {
-		cancel.(chan struct{}) <- struct{}{}
-		d.cancelDownloads.Delete(url)
-	}
-
-	// Supprimer de la base de données
-	err := d.OnDeleted(url, deleteFile)
-	if err != nil {
+	delete(d.diskPaused, id)
+	if err := d.checkTransition(id, StatusDeleted); err != nil {
 		return err
 	}
 
-	// Supprimer des téléchargements en cours
-	d.activeDownloads.Delete(url)
+	// Arrêter le téléchargement s'il est en cours et attendre qu'il ait fermé le fichier
+	<-d.stopJob(id, ErrDeleted)
 
+	d.emit(Event{Type: EventDeleted, ID: id, DeleteFile: deleteFile})
 	return nil
 }
 
-func (d *Downloader) PauseDownload(url string) error {
-	d.pausedDownloads.Store(url, struct{}{})
-	return d.OnPause(url)
+// PauseDownload interrompt le téléchargement : l'avancement est enregistré, puis
+// la connexion, le fichier et la place dans la file sont libérés. ResumeDownload
+// le reprendra à partir des octets déjà écrits.
+func (d *Downloader) PauseDownload(id int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Une pause demandée par l'utilisateur n'est pas levée au retour de l'espace disque
+	delete(d.diskPaused, id)
+	_, err := d.pauseLocked(id)
+	return err
 }
 
-func (d *Downloader) ResumeDownload(url string) error {
-	d.pausedDownloads.Delete(url)
-	err := d.OnResume(url)
-	if err != nil {
-		return err
+// pauseLocked met le téléchargement en pause sous d.mu ; false si le
+// téléchargement s'est terminé avant que la pause ne prenne effet
+func (d *Downloader) pauseLocked(id int64) (bool, error) {
+	if err := d.checkTransition(id, StatusPaused); err != nil {
+		return false, err
 	}
 
-	// Relancer le téléchargement
-	go func() {
-		err := d.Download(url)
-		if err != nil {
-			// Gérer l'erreur (par exemple, mettre à jour le statut dans la base de données)
-			d.OnError(url, err)
+	event := Event{Type: EventPaused, ID: id}
+	if value, active := d.jobs.Load(id); active {
+		j := value.(*job)
+		j.cancel(ErrPaused)
+		<-j.done
+		if !errors.Is(j.result, ErrPaused) {
+			// Terminé ou échoué avant que la pause ne prenne effet : l'événement
+			// publié par finishJob fait foi
+			return false, nil
 		}
-	}()
+		event = j.progressEvent(EventPaused)
+	}
+
+	d.emit(event)
+	return true, nil
+}
+
+// ResumeDownload relance un téléchargement en pause ; il ne peut y avoir qu'un
+// seul job actif par téléchargement
+func (d *Downloader) ResumeDownload(id int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.diskPaused, id)
+	return d.resumeLocked(id)
+}
+
+// resumeLocked relance le téléchargement sous d.mu
+func (d *Downloader) resumeLocked(id int64) error {
+	if _, active := d.jobs.Load(id); active {
+		return nil
+	}
+	if err := d.checkTransition(id, StatusPending); err != nil {
+		return err
+	}
+
+	// Relancer le téléchargement à partir des données déjà écrites ; il n'est
+	// annoncé comme repris qu'une fois son job créé
+	ctx, j, err := d.enqueueResume(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	d.emit(Event{Type: EventResumed, ID: id})
+	// Un échec est publié par finishJob
+	go d.download(ctx, j, true)
 
 	return nil
 }
 
-func (d *Downloader) CancelDownload(url string) error {
-	if cancel, ok := d.cancelDownloads.Load(url); ok {
-		close(cancel.(chan struct{}))
+// CancelDownload arrête le téléchargement sans qu'il puisse être repris ; ses
+// fichiers temporaires sont supprimés
+func (d *Downloader) CancelDownload(id int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.diskPaused, id)
+	if err := d.checkTransition(id, StatusCancelled); err != nil {
+		return err
 	}
-	d.pausedDownloads.Delete(url)
-	return d.OnCancel(url)
+	<-d.stopJob(id, ErrCancelled)
+	d.emit(Event{Type: EventCancelled, ID: id})
+	return nil
 }
 
-func (d *Downloader) SetDownloadStatusDeleted(url string) error {
-	return d.OnDeleted(url, false)
+// SetDownloadStatusDeleted retire le téléchargement de la liste en conservant
+// le fichier ; comme DeleteDownload, il arrête d'abord le transfert en cours
+func (d *Downloader) SetDownloadStatusDeleted(id int64) error {
+	return d.DeleteDownload(id, false)
 }
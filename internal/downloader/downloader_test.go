@@ -0,0 +1,360 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore est un Store en mémoire : il suffit à reprendre un téléchargement
+type memoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	states map[int64]ResumeState
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{states: make(map[int64]ResumeState)}
+}
+
+func (s *memoryStore) AddDownload(url string, totalSize int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	s.states[s.nextID] = ResumeState{URL: url, Size: totalSize}
+	return s.nextID, nil
+}
+
+func (s *memoryStore) SaveDownloadState(id int64, state ResumeState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[id] = state
+	return nil
+}
+
+func (s *memoryStore) GetDownloadState(id int64) (*ResumeState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[id]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// Le statut est celui que le Downloader retient de ses propres événements
+func (s *memoryStore) GetDownloadStatus(id int64) (Status, error) {
+	return "", nil
+}
+
+// rangeServer sert testData avec un ETag, pour que la reprise soit possible, et
+// retient les plages demandées par les requêtes GET
+type rangeServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	ranges []string
+	gate   chan struct{} // Si non nil, les requêtes GET attendent sa fermeture
+}
+
+func startRangeServer(t *testing.T) *rangeServer {
+	t.Helper()
+	s := &rangeServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.mu.Lock()
+			s.ranges = append(s.ranges, r.Header.Get("Range"))
+			gate := s.gate
+			s.mu.Unlock()
+			if gate != nil {
+				select {
+				case <-gate:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Unix(0, 0), bytes.NewReader(testData))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// hold fait attendre les requêtes GET jusqu'à l'appel de la fonction retournée
+func (s *rangeServer) hold() (release func()) {
+	gate := make(chan struct{})
+	s.mu.Lock()
+	s.gate = gate
+	s.mu.Unlock()
+	return sync.OnceFunc(func() { close(gate) })
+}
+
+// requestedRanges retourne les en-têtes Range des requêtes GET reçues
+func (s *rangeServer) requestedRanges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+// Le fichier est téléchargé en plusieurs requêtes Range qui en couvrent chacune une partie
+func TestRangedDownload(t *testing.T) {
+	server := startRangeServer(t)
+	d := newTestDownloader(t)
+
+	data, err := fetch(d, server.URL+"/file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, testData) {
+		t.Fatal("contenu du fichier téléchargé différent de celui servi")
+	}
+
+	ranges := server.requestedRanges()
+	if len(ranges) < 2 {
+		t.Fatalf("plages demandées %q, attendu au moins 2 requêtes Range", ranges)
+	}
+	for _, r := range ranges {
+		var start, end int64
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil || start > end || end >= int64(len(testData)) {
+			t.Errorf("plage %q invalide", r)
+		}
+		if start == 0 && end == int64(len(testData))-1 {
+			t.Errorf("le fichier entier a été demandé en une seule requête : %q", ranges)
+		}
+	}
+
+	filePath := filepath.Join(d.DownloadDir, "file.bin")
+	for _, path := range []string{partPath(filePath), sidecarPath(filePath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s subsiste après le téléchargement : %v", path, err)
+		}
+	}
+}
+
+// waitEvent lit les événements jusqu'à celui de l'un des types donnés pour le
+// téléchargement id, et échoue au bout de 10 secondes
+func waitEvent(t *testing.T, sub *Subscription, id int64, types ...EventType) Event {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				t.Fatalf("abonnement fermé avant l'événement %v du téléchargement %d", types, id)
+			}
+			if event.ID == id && slices.Contains(types, event.Type) {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("événement %v du téléchargement %d non reçu", types, id)
+		}
+	}
+}
+
+// partialState écrit le fichier temporaire d'un téléchargement de testData
+// interrompu : les downloaded premiers octets de chacun des deux chunks, le reste
+// à zéro comme après la préallocation. Il retourne l'état correspondant.
+func partialState(t *testing.T, url, filePath string, downloaded int64) ResumeState {
+	t.Helper()
+	chunks := splitChunks(int64(len(testData)), 2)
+	data := make([]byte, len(testData))
+	for i := range chunks {
+		chunks[i].Downloaded = downloaded
+		copy(data[chunks[i].Start:chunks[i].Start+downloaded], testData[chunks[i].Start:])
+	}
+	if err := os.WriteFile(partPath(filePath), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return ResumeState{
+		URL:      url,
+		FileName: filepath.Base(filePath),
+		FilePath: filePath,
+		Size:     int64(len(testData)),
+		ETag:     `"v1"`,
+		Chunks:   chunks,
+	}
+}
+
+// resumeAndWait reprend le téléchargement id, en pause, et attend qu'il se termine
+func resumeAndWait(t *testing.T, d *Downloader, id int64) {
+	t.Helper()
+	sub := d.Subscribe(DefaultEventBuffer)
+	defer sub.Close()
+	d.emit(Event{Type: EventPaused, ID: id})
+
+	if err := d.ResumeDownload(id); err != nil {
+		t.Fatal(err)
+	}
+	if event := waitEvent(t, sub, id, EventCompleted, EventFailed); event.Type != EventCompleted {
+		t.Fatalf("reprise : %v", event)
+	}
+}
+
+// checkResumed vérifie que le fichier est complet et que seuls les octets
+// manquants ont été demandés au serveur
+func checkResumed(t *testing.T, server *rangeServer, filePath string, missing int64) {
+	t.Helper()
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, testData) {
+		t.Error("contenu du fichier repris différent de celui servi")
+	}
+
+	var requested int64
+	for _, r := range server.requestedRanges() {
+		var start, end int64
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil {
+			t.Fatalf("plage %q invalide", r)
+		}
+		requested += end - start + 1
+	}
+	if requested != missing {
+		t.Errorf("%d octets demandés, attendu les %d manquants : %q", requested, missing, server.requestedRanges())
+	}
+}
+
+// Une reprise ne demande que les octets manquants de chaque chunk enregistré
+func TestResumeFromSavedState(t *testing.T) {
+	server := startRangeServer(t)
+	d := newTestDownloader(t)
+	store := newMemoryStore()
+	d.Store = store
+
+	const downloaded = 300000
+	filePath := filepath.Join(d.DownloadDir, "file.bin")
+	store.states[1] = partialState(t, server.URL+"/file.bin", filePath, downloaded)
+
+	resumeAndWait(t, d, 1)
+	checkResumed(t, server, filePath, int64(len(testData))-2*downloaded)
+}
+
+// Sans avancement dans le Store, celui écrit à côté du fichier temporaire permet
+// la reprise ; les fichiers temporaires disparaissent une fois le fichier complet
+func TestResumeFromSidecar(t *testing.T) {
+	server := startRangeServer(t)
+	d := newTestDownloader(t)
+	store := newMemoryStore()
+	d.Store = store
+
+	const downloaded = 400000
+	filePath := filepath.Join(d.DownloadDir, "file.bin")
+	state := partialState(t, server.URL+"/file.bin", filePath, downloaded)
+	if err := writeSidecar(state); err != nil {
+		t.Fatal(err)
+	}
+	store.states[1] = ResumeState{URL: state.URL, FileName: state.FileName, FilePath: filePath}
+
+	resumeAndWait(t, d, 1)
+	checkResumed(t, server, filePath, int64(len(testData))-2*downloaded)
+	for _, path := range []string{partPath(filePath), sidecarPath(filePath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s subsiste après le téléchargement : %v", path, err)
+		}
+	}
+}
+
+// La même URL ajoutée deux fois donne deux téléchargements indépendants, chacun
+// avec son ID et son fichier
+func TestSameURLTwice(t *testing.T) {
+	server := startRangeServer(t)
+	d := newTestDownloader(t)
+	d.Store = newMemoryStore()
+
+	var reqs []Request
+	for range 2 {
+		req, err := d.Add(server.URL + "/file.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, req)
+	}
+	if reqs[0].ID == reqs[1].ID {
+		t.Fatalf("même ID %d attribué aux deux téléchargements", reqs[0].ID)
+	}
+
+	for i, err := range d.DownloadMultiple(reqs) {
+		if err != nil {
+			t.Fatalf("téléchargement %d : %v", reqs[i].ID, err)
+		}
+	}
+	for _, name := range []string{"file.bin", "file (1).bin"} {
+		data, err := os.ReadFile(filepath.Join(d.DownloadDir, name))
+		if err != nil || !bytes.Equal(data, testData) {
+			t.Errorf("%s : contenu inattendu, erreur %v", name, err)
+		}
+	}
+}
+
+// Un serveur qui refuse HEAD est interrogé par une requête GET limitée au premier
+// octet ; s'il ignore l'en-tête Range et n'annonce pas de taille, le fichier est
+// reçu d'une traite jusqu'à la fin de la réponse
+func TestDownloadWithoutHEAD(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranged bool // Le serveur accepte les requêtes Range et annonce la taille
+	}{
+		{"requêtes Range", true},
+		{"taille inconnue", false},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var requests []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests = append(requests, strings.TrimSpace(r.Method+" "+r.Header.Get("Range")))
+			mu.Unlock()
+
+			switch {
+			case r.Method == http.MethodHead:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			case tt.ranged:
+				http.ServeContent(w, r, "", time.Unix(0, 0), bytes.NewReader(testData))
+			default:
+				// Réponse sans Content-Length, envoyée par morceaux
+				w.Header().Set("Content-Type", "application/octet-stream")
+				for offset := 0; offset < len(testData); offset += 100000 {
+					w.Write(testData[offset : offset+100000])
+					w.(http.Flusher).Flush()
+				}
+			}
+		}))
+		defer server.Close()
+
+		d := newTestDownloader(t)
+		sub := d.Subscribe(DefaultEventBuffer)
+		data, err := fetch(d, server.URL+"/file.bin")
+		sub.Close()
+		if err != nil {
+			t.Fatalf("%s : %v", tt.name, err)
+		}
+		if !bytes.Equal(data, testData) {
+			t.Errorf("%s : contenu du fichier téléchargé différent de celui servi", tt.name)
+		}
+		if len(requests) < 3 || requests[0] != "HEAD" || requests[1] != "GET bytes=0-0" {
+			t.Errorf("%s : requêtes %q, attendu HEAD puis GET bytes=0-0", tt.name, requests)
+		}
+
+		// Sans taille annoncée, la progression est indéterminée jusqu'à la fin
+		for event := range sub.Events() {
+			if event.Type == EventProgress && tt.ranged != (event.Total == int64(len(testData))) {
+				t.Errorf("%s : progression %d/%d", tt.name, event.Downloaded, event.Total)
+			}
+			if event.Type == EventCompleted && event.Total != int64(len(testData)) {
+				t.Errorf("%s : taille %d une fois terminé, attendu %d", tt.name, event.Total, len(testData))
+			}
+		}
+	}
+}
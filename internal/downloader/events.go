@@ -0,0 +1,236 @@
+package downloader
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultEventBuffer est la capacité conseillée d'un abonnement : assez pour
+// absorber les rafales de progression sans ralentir les téléchargements
+const DefaultEventBuffer = 256
+
+// EventType distingue les événements publiés par le Downloader
+type EventType int
+
+const (
+	EventAdded              EventType = iota + 1 // Téléchargement enregistré
+	EventStarted                                 // Place obtenue dans la file, transfert en cours
+	EventProgress                                // Octets reçus
+	EventPaused                                  // Mis en pause, avancement enregistré
+	EventResumed                                 // Reprise demandée
+	EventCompleted                               // Fichier complet, vérifié et renommé
+	EventFailed                                  // Échec définitif, Err en donne la cause
+	EventCancelled                               // Annulé, ne sera pas repris
+	EventDeleted                                 // Supprimé de la liste
+	EventQueueChanged                            // Nouvel ordre de la file d'attente
+	EventLowDiskSpace                            // Téléchargements mis en pause faute d'espace disque
+	EventDiskSpaceRecovered                      // Téléchargements repris après libération d'espace
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAdded:
+		return "ajouté"
+	case EventStarted:
+		return "démarré"
+	case EventProgress:
+		return "progression"
+	case EventPaused:
+		return "en pause"
+	case EventResumed:
+		return "repris"
+	case EventCompleted:
+		return "terminé"
+	case EventFailed:
+		return "échoué"
+	case EventCancelled:
+		return "annulé"
+	case EventDeleted:
+		return "supprimé"
+	case EventQueueChanged:
+		return "file modifiée"
+	case EventLowDiskSpace:
+		return "espace disque insuffisant"
+	case EventDiskSpaceRecovered:
+		return "espace disque suffisant"
+	default:
+		return fmt.Sprintf("événement %d", int(t))
+	}
+}
+
+// Event décrit ce qui est arrivé à un téléchargement ; seuls les champs propres
+// à son type sont renseignés
+type Event struct {
+	Type       EventType
+	ID         int64 // Nul pour les événements qui ne concernent pas un seul téléchargement
+	Time       time.Time
+	URL        string  // EventAdded
+	Downloaded int64   // Octets écrits : EventProgress, EventPaused, EventCompleted
+	Total      int64   // Taille du fichier, négative si inconnue, avec Downloaded
+	Err        error   // EventFailed
+	DeleteFile bool    // EventDeleted : le fichier téléchargé doit aussi être supprimé
+	IDs        []int64 // Ordre de la file, ou téléchargements mis en pause puis repris faute d'espace
+	Available  int64   // Espace disque disponible, en octets
+}
+
+// Progress retourne la part téléchargée, ou ProgressUnknown si la taille n'est pas connue
+func (e Event) Progress() float64 {
+	if e.Type == EventCompleted {
+		return 1
+	}
+	return progressRatio(e.Downloaded, e.Total)
+}
+
+func (e Event) String() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("téléchargement %d %v : %v", e.ID, e.Type, e.Err)
+	case e.ID != 0:
+		return fmt.Sprintf("téléchargement %d %v", e.ID, e.Type)
+	default:
+		return e.Type.String()
+	}
+}
+
+// Subscription reçoit les événements publiés après Subscribe, dans l'ordre. Un
+// abonné en retard ralentit le Downloader une fois sa file pleine ; seuls les
+// événements de progression sont alors perdus, le suivant les remplaçant. Un
+// abonné qui appelle le Downloader depuis la boucle de lecture doit donc le
+// faire dans une autre goroutine.
+type Subscription struct {
+	bus     *eventBus
+	events  chan Event
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.RWMutex // Protège la fermeture de events pendant un envoi
+	closed  bool
+	dropped atomic.Int64
+}
+
+// Events retourne le canal des événements, fermé par Close ou Shutdown après
+// les derniers événements publiés
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped retourne le nombre d'événements de progression perdus faute de place
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close met fin à l'abonnement ; les événements en attente restent lisibles
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.bus.remove(s)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.events)
+	})
+}
+
+// send transmet l'événement, en attendant une place sauf pour la progression
+func (s *Subscription) send(event Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+	if event.Type == EventProgress {
+		select {
+		case s.events <- event:
+		default:
+			s.dropped.Add(1)
+		}
+		return
+	}
+	select {
+	case s.events <- event:
+	case <-s.done:
+	}
+}
+
+// eventBus diffuse les événements à tous les abonnés
+type eventBus struct {
+	mu     sync.Mutex
+	subs   []*Subscription
+	closed bool
+}
+
+func (b *eventBus) subscribe(buffer int) *Subscription {
+	s := &Subscription{bus: b, events: make(chan Event, max(buffer, 1)), done: make(chan struct{})}
+
+	b.mu.Lock()
+	closed := b.closed
+	if !closed {
+		b.subs = append(b.subs, s)
+	}
+	b.mu.Unlock()
+
+	if closed {
+		s.Close()
+	}
+	return s
+}
+
+func (b *eventBus) remove(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish envoie l'événement aux abonnés ; il est délivré hors du verrou pour
+// qu'un abonné lent ne bloque pas les abonnements et désabonnements
+func (b *eventBus) publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	subs := b.subs
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.send(event)
+	}
+}
+
+// close ferme les abonnements une fois les derniers événements publiés
+func (b *eventBus) close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.closed = true
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.Close()
+	}
+}
+
+// Subscribe abonne l'appelant aux événements, avec une file de buffer événements
+func (d *Downloader) Subscribe(buffer int) *Subscription {
+	return d.eventSubscribers.subscribe(buffer)
+}
+
+// emit publie un événement du Downloader et retient le statut auquel il fait
+// passer le téléchargement, jusqu'à sa suppression
+func (d *Downloader) emit(event Event) {
+	switch status, ok := event.Status(); {
+	case event.Type == EventDeleted:
+		d.statuses.Delete(event.ID)
+	case ok:
+		d.statuses.Store(event.ID, status)
+	}
+	d.eventSubscribers.publish(event)
+}
@@ -0,0 +1,121 @@
+package downloader
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// receive lit les événements disponibles sans attendre
+func receive(sub *Subscription) []EventType {
+	var types []EventType
+	for len(sub.Events()) > 0 {
+		types = append(types, (<-sub.Events()).Type)
+	}
+	return types
+}
+
+// Les événements sont reçus dans l'ordre de publication, un abonné lent
+// ralentissant la publication plutôt que de perdre un changement de statut
+func TestSubscriptionOrder(t *testing.T) {
+	var bus eventBus
+	sub := bus.subscribe(1)
+
+	want := []EventType{EventAdded, EventStarted, EventPaused, EventResumed, EventStarted, EventCompleted}
+	go func() {
+		for _, eventType := range want {
+			bus.publish(Event{Type: eventType, ID: 1})
+		}
+		bus.close()
+	}()
+
+	var got []EventType
+	for event := range sub.Events() {
+		got = append(got, event.Type)
+		if event.Time.IsZero() {
+			t.Errorf("événement %v sans date", event)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("événements %v, attendu %v", got, want)
+	}
+}
+
+// Une file pleine perd les événements de progression mais attend une place pour
+// les autres
+func TestSubscriptionDropsProgress(t *testing.T) {
+	var bus eventBus
+	sub := bus.subscribe(2)
+
+	for range 5 {
+		bus.publish(Event{Type: EventProgress, ID: 1})
+	}
+	if dropped := sub.Dropped(); dropped != 3 {
+		t.Errorf("%d événements de progression perdus, attendu 3", dropped)
+	}
+
+	published := make(chan struct{})
+	go func() {
+		bus.publish(Event{Type: EventCompleted, ID: 1})
+		close(published)
+	}()
+	select {
+	case <-published:
+		t.Fatal("événement publié dans une file pleine")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Une place libérée suffit à débloquer la publication
+	if event := <-sub.Events(); event.Type != EventProgress {
+		t.Errorf("premier événement %v, attendu la progression", event)
+	}
+	<-published
+	if got, want := receive(sub), []EventType{EventProgress, EventCompleted}; !slices.Equal(got, want) {
+		t.Errorf("événements %v, attendu %v", got, want)
+	}
+}
+
+// Close débloque une publication en attente ; l'abonnement ne reçoit plus rien
+func TestSubscriptionClose(t *testing.T) {
+	var bus eventBus
+	sub := bus.subscribe(1)
+	other := bus.subscribe(DefaultEventBuffer)
+
+	bus.publish(Event{Type: EventAdded, ID: 1})
+	published := make(chan struct{})
+	go func() {
+		bus.publish(Event{Type: EventStarted, ID: 1})
+		close(published)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sub.Close()
+	<-published
+
+	bus.publish(Event{Type: EventCompleted, ID: 1})
+	if got := receive(sub); !slices.Equal(got, []EventType{EventAdded}) {
+		t.Errorf("événements %v après Close, attendu le seul événement déjà reçu", got)
+	}
+	if got, want := receive(other), []EventType{EventAdded, EventStarted, EventCompleted}; !slices.Equal(got, want) {
+		t.Errorf("autre abonné : événements %v, attendu %v", got, want)
+	}
+}
+
+// Le statut retenu est celui du dernier événement publié, jusqu'à la suppression
+func TestEmitStatus(t *testing.T) {
+	d := newTestDownloader(t)
+	for _, tt := range []struct {
+		event Event
+		want  Status
+	}{
+		{Event{Type: EventStarted, ID: 1}, StatusDownloading},
+		{Event{Type: EventProgress, ID: 1}, StatusDownloading},
+		{Event{Type: EventPaused, ID: 1}, StatusPaused},
+		{Event{Type: EventQueueChanged, IDs: []int64{1}}, StatusPaused},
+		{Event{Type: EventDeleted, ID: 1}, ""},
+	} {
+		d.emit(tt.event)
+		if status, _ := d.status(1); status != tt.want {
+			t.Errorf("statut %q après %v, attendu %q", status, tt.event, tt.want)
+		}
+	}
+}
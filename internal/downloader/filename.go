@@ -0,0 +1,160 @@
+package downloader
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Nom utilisé lorsque ni l'en-tête ni l'URL ne fournissent de nom exploitable
+const defaultFileName = "download"
+
+// Longueur maximale d'un nom de fichier sur la plupart des systèmes de fichiers
+const fileSystemNameLimit = 255
+
+// Longueur maximale d'un nom résolu : la place du suffixe de renommage et de
+// celui du fichier temporaire le plus long est réservée, sans quoi un nom long
+// ne pourrait être ni créé ni renommé
+const maxFileNameLength = fileSystemNameLimit - maxRenameSuffixLength - len(sidecarTempSuffix)
+
+// Extensions usuelles pour les types MIME auxquels la table du système associe
+// plusieurs extensions, la première par ordre alphabétique n'étant pas la bonne
+var preferredExtensions = map[string]string{
+	"text/html":                ".html",
+	"text/plain":               ".txt",
+	"image/jpeg":               ".jpg",
+	"audio/mpeg":               ".mp3",
+	"video/mpeg":               ".mpeg",
+	"application/octet-stream": "",
+}
+
+// Noms réservés par Windows, quelle que soit l'extension
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// resolveFileName choisit le nom du fichier local à partir de la réponse du
+// serveur : Content-Disposition (filename* RFC 5987 compris), puis chemin de
+// l'URL finale après redirections, puis extension déduite du type MIME
+func resolveFileName(resp *http.Response) string {
+	name := fileNameFromDisposition(resp.Header.Get("Content-Disposition"))
+
+	if name == "" && resp.Request != nil && resp.Request.URL != nil {
+		name = fileNameFromURL(resp.Request.URL)
+	}
+
+	name = sanitizeFileName(name)
+	if name == "" {
+		name = defaultFileName
+	}
+
+	if path.Ext(name) == "" {
+		// L'extension ajoutée ne doit pas faire dépasser la longueur maximale
+		name = truncateFileName(name+extensionFromContentType(resp.Header.Get("Content-Type")), maxFileNameLength)
+	}
+
+	return name
+}
+
+// fileNameFromDisposition extrait le nom de fichier de l'en-tête ; mime décode
+// déjà la forme étendue filename* et lui donne la priorité
+func fileNameFromDisposition(disposition string) string {
+	if disposition == "" {
+		return ""
+	}
+
+	_, params, err := mime.ParseMediaType(disposition)
+	if err != nil {
+		return ""
+	}
+	return params["filename"]
+}
+
+// fileNameFromURL retourne le dernier segment décodé du chemin de l'URL
+func fileNameFromURL(u *url.URL) string {
+	p := u.Path
+	if unescaped, err := url.PathUnescape(u.EscapedPath()); err == nil {
+		p = unescaped
+	}
+
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return ""
+	}
+	return path.Base(p)
+}
+
+// extensionFromContentType retourne l'extension correspondant au type MIME, ou ""
+func extensionFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+
+	extensions, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(extensions) == 0 {
+		return ""
+	}
+	return extensions[0]
+}
+
+// sanitizeFileName rend le nom utilisable sur tous les systèmes de fichiers :
+// séparateurs, caractères réservés et de contrôle sont remplacés, les noms
+// réservés de Windows préfixés et la longueur limitée
+func sanitizeFileName(name string) string {
+	// Ne garder que le dernier composant pour empêcher toute sortie du dossier
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Windows ignore les points et espaces finaux ; un point initial cacherait le fichier
+	name = strings.Trim(name, " .")
+
+	base := strings.ToUpper(strings.TrimSuffix(name, path.Ext(name)))
+	if reservedFileNames[base] {
+		name = "_" + name
+	}
+
+	return truncateFileName(name, maxFileNameLength)
+}
+
+// truncateFileName raccourcit le nom à max octets en conservant l'extension
+func truncateFileName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+
+	ext := path.Ext(name)
+	if len(ext) >= max {
+		ext = ""
+	}
+	base := name[:len(name)-len(ext)]
+	limit := max - len(ext)
+	for limit > 0 && !utf8.RuneStart(base[limit]) {
+		limit--
+	}
+	return base[:limit] + ext
+}
@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// Un nom long annoncé par le serveur est raccourci de façon à laisser la place
+// aux suffixes du fichier temporaire et du renommage
+func TestLongDispositionFileName(t *testing.T) {
+	name := strings.Repeat("a", 300) + ".bin"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		http.ServeContent(w, r, "", time.Unix(0, 0), bytes.NewReader(testData))
+	}))
+	t.Cleanup(server.Close)
+
+	d := newTestDownloader(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Le second téléchargement est renommé en "nom (1).bin"
+	for range 2 {
+		if err := d.DownloadContext(ctx, Request{URL: server.URL + "/file"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(d.DownloadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d fichiers obtenus, 2 attendus", len(entries))
+	}
+	for _, entry := range entries {
+		if got := entry.Name(); len(got) > maxFileNameLength+maxRenameSuffixLength || !strings.HasSuffix(got, ".bin") {
+			t.Errorf("nom inattendu %q (%d octets)", got, len(got))
+		}
+	}
+}
+
+// L'extension déduite du type MIME ne fait pas dépasser la longueur maximale
+func TestLongURLFileNameWithContentType(t *testing.T) {
+	resp := &http.Response{
+		Header:  http.Header{"Content-Type": {"text/html"}},
+		Request: httptest.NewRequest(http.MethodGet, "http://example.com/"+strings.Repeat("a", 300), nil),
+	}
+	if got := resolveFileName(resp); len(got) > maxFileNameLength || !strings.HasSuffix(got, ".html") {
+		t.Errorf("resolveFileName = %q (%d octets)", got, len(got))
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"rapport.pdf", "rapport.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Windows\system.ini`, "system.ini"},
+		{"a<b>c:d|e?f*.txt", "a_b_c_d_e_f_.txt"},
+		{"ligne\nsuivante.txt", "lignesuivante.txt"},
+		{" .caché. ", "caché"},
+		{"CON.txt", "_CON.txt"},
+		{"con", "_con"},
+		{"..", ""},
+		{"/", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFileName(tt.name); got != tt.want {
+			t.Errorf("sanitizeFileName(%q) = %q, attendu %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		want string
+	}{
+		{"court.txt", 20, "court.txt"},
+		{"abcdefghij.txt", 10, "abcdef.txt"},
+		{"abcdefghij", 4, "abcd"},
+		{"éééé.txt", 8, "éé.txt"}, // Une rune n'est jamais coupée
+		{"é.txt", 5, ".txt"},
+		{"a.extension", 5, "a.ext"}, // Extension plus longue que la limite
+	}
+	for _, tt := range tests {
+		got := truncateFileName(tt.name, tt.max)
+		if got != tt.want {
+			t.Errorf("truncateFileName(%q, %d) = %q, attendu %q", tt.name, tt.max, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateFileName(%q, %d) = %q n'est pas de l'UTF-8 valide", tt.name, tt.max, got)
+		}
+	}
+}
+
+func TestResolveFileName(t *testing.T) {
+	tests := []struct {
+		disposition string
+		url         string
+		contentType string
+		want        string
+	}{
+		{`attachment; filename="rapport.pdf"`, "http://example.com/dl?id=1", "", "rapport.pdf"},
+		{`attachment; filename="fallback.txt"; filename*=UTF-8''r%C3%A9sum%C3%A9.txt`, "http://example.com/", "", "résumé.txt"},
+		{`attachment; filename="../secret"`, "http://example.com/", "", "secret"},
+		{"", "http://example.com/dossier/mon%20fichier.zip", "", "mon fichier.zip"},
+		{"", "http://example.com/dossier/", "", "dossier"},
+		{"", "http://example.com/page", "text/html; charset=utf-8", "page.html"},
+		{"", "http://example.com/", "application/octet-stream", "download"},
+		{"", "http://example.com/", "image/jpeg", "download.jpg"},
+		{"attachment; filename=", "http://example.com/archive.tar.gz", "", "archive.tar.gz"},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{
+			Header:  http.Header{},
+			Request: httptest.NewRequest(http.MethodGet, tt.url, nil),
+		}
+		if tt.disposition != "" {
+			resp.Header.Set("Content-Disposition", tt.disposition)
+		}
+		if tt.contentType != "" {
+			resp.Header.Set("Content-Type", tt.contentType)
+		}
+		if got := resolveFileName(resp); got != tt.want {
+			t.Errorf("resolveFileName(%q, %q, %q) = %q, attendu %q", tt.disposition, tt.url, tt.contentType, got, tt.want)
+		}
+	}
+}
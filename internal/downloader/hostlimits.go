@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HostLimit borne la charge imposée à un hôte : téléchargements simultanés et
+// connexions ouvertes, tous téléchargements confondus ; 0 signifie illimité
+type HostLimit struct {
+	Pattern        string // Nom d'hôte, éventuellement avec jokers : "*.example.org"
+	MaxDownloads   int
+	MaxConnections int
+}
+
+// DefaultHostLimit retourne la limite appliquée aux hôtes sans règle particulière
+// tant qu'aucun paramètre n'est enregistré
+func DefaultHostLimit() HostLimit {
+	return HostLimit{MaxDownloads: 2, MaxConnections: 4}
+}
+
+// ParseHostLimits lit une règle par ligne au format "motif téléchargements connexions" ;
+// les lignes vides et celles commençant par # sont ignorées
+func ParseHostLimits(text string) ([]HostLimit, error) {
+	var limits []HostLimit
+
+	err := scanLines(text, func(line int, fields []string) error {
+		if len(fields) != 3 {
+			return fmt.Errorf("ligne %d : format attendu « motif téléchargements connexions »", line)
+		}
+
+		pattern, err := parsePattern(fields[0])
+		if err != nil {
+			return fmt.Errorf("ligne %d : %v", line, err)
+		}
+		downloads, err := strconv.Atoi(fields[1])
+		if err != nil || downloads < 0 {
+			return fmt.Errorf("ligne %d : nombre de téléchargements invalide : %s", line, fields[1])
+		}
+		connections, err := strconv.Atoi(fields[2])
+		if err != nil || connections < 0 {
+			return fmt.Errorf("ligne %d : nombre de connexions invalide : %s", line, fields[2])
+		}
+
+		limits = append(limits, HostLimit{Pattern: pattern, MaxDownloads: downloads, MaxConnections: connections})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+// scanLines lit une liste à une règle par ligne et appelle parse avec le numéro
+// et les champs de chaque ligne ; les lignes vides et les commentaires # sont ignorés
+func scanLines(text string, parse func(line int, fields []string) error) error {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if err := parse(line, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePattern valide un motif d'hôte et le met en minuscules
+func parsePattern(pattern string) (string, error) {
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", fmt.Errorf("motif invalide : %s", pattern)
+	}
+	return pattern, nil
+}
+
+// FormatHostLimits est l'inverse de ParseHostLimits
+func FormatHostLimits(limits []HostLimit) string {
+	lines := make([]string, len(limits))
+	for i, limit := range limits {
+		lines[i] = fmt.Sprintf("%s %d %d", limit.Pattern, limit.MaxDownloads, limit.MaxConnections)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hostOf retourne le nom d'hôte de l'URL, en minuscules
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// hostLimits associe à chaque hôte la première règle dont le motif correspond,
+// ou la limite par défaut
+type hostLimits struct {
+	mu        sync.RWMutex
+	def       HostLimit
+	overrides []HostLimit
+}
+
+func (l *hostLimits) set(def HostLimit, overrides []HostLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.def = def
+	l.overrides = overrides
+}
+
+func (l *hostLimits) get() (HostLimit, []HostLimit) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.def, append([]HostLimit(nil), l.overrides...)
+}
+
+func (l *hostLimits) forHost(host string) HostLimit {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, limit := range l.overrides {
+		if matched, _ := path.Match(limit.Pattern, host); matched {
+			return limit
+		}
+	}
+	return l.def
+}
+
+// hostConnections compte les connexions ouvertes par hôte ; la limite est relue
+// à chaque tentative pour qu'un changement de paramètres s'applique aussitôt
+type hostConnections struct {
+	mu      sync.Mutex
+	active  map[string]int
+	changed chan struct{} // Fermé, puis remplacé, à chaque connexion libérée
+	limit   func(host string) int
+}
+
+func newHostConnections(limit func(host string) int) *hostConnections {
+	return &hostConnections{
+		active:  make(map[string]int),
+		changed: make(chan struct{}),
+		limit:   limit,
+	}
+}
+
+// acquire attend qu'une connexion vers host soit disponible et retourne la
+// fonction qui la libère
+func (c *hostConnections) acquire(ctx context.Context, host string) (func(), error) {
+	for {
+		c.mu.Lock()
+		if limit := c.limit(host); limit <= 0 || c.active[host] < limit {
+			c.active[host]++
+			c.mu.Unlock()
+			return func() { c.release(host) }, nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-changed:
+		}
+	}
+}
+
+func (c *hostConnections) release(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.active[host]--; c.active[host] <= 0 {
+		delete(c.active, host)
+	}
+	c.notifyLocked()
+}
+
+func (c *hostConnections) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// SetHostLimits remplace la limite par défaut et les règles par hôte ; les
+// téléchargements et connexions en attente sont réévalués aussitôt
+func (d *Downloader) SetHostLimits(def HostLimit, overrides []HostLimit) {
+	d.hostLimits.set(def, overrides)
+
+	d.connections.mu.Lock()
+	d.connections.notifyLocked()
+	d.connections.mu.Unlock()
+
+	d.queue.redispatch()
+}
+
+// HostLimits retourne la limite par défaut et les règles par hôte
+func (d *Downloader) HostLimits() (HostLimit, []HostLimit) {
+	return d.hostLimits.get()
+}
+
+// chunkCount retourne le nombre de chunks d'un téléchargement : inutile d'en
+// créer plus que de connexions autorisées vers l'hôte
+func (d *Downloader) chunkCount(host string) int {
+	count := d.MaxChunks
+	if limit := d.hostLimits.forHost(host).MaxConnections; limit > 0 && limit < count {
+		count = limit
+	}
+	return count
+}
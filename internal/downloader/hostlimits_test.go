@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseHostLimits(t *testing.T) {
+	text := `# motif téléchargements connexions
+example.com 1 2
+
+*.CDN.example.org 0 8`
+	limits, err := ParseHostLimits(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HostLimit{
+		{Pattern: "example.com", MaxDownloads: 1, MaxConnections: 2},
+		{Pattern: "*.cdn.example.org", MaxDownloads: 0, MaxConnections: 8},
+	}
+	if !slices.Equal(limits, want) {
+		t.Errorf("ParseHostLimits = %+v, attendu %+v", limits, want)
+	}
+	if got, err := ParseHostLimits(FormatHostLimits(want)); err != nil || !slices.Equal(got, want) {
+		t.Errorf("relecture de FormatHostLimits : %+v, %v", got, err)
+	}
+
+	for _, invalid := range []string{
+		"example.com 1",
+		"example.com 1 2 3",
+		"[ 1 2",
+		"example.com -1 2",
+		"example.com 1 beaucoup",
+	} {
+		if _, err := ParseHostLimits("# valide\nexample.org 1 1\n" + invalid); err == nil {
+			t.Errorf("ParseHostLimits(%q) accepté", invalid)
+		} else if !strings.HasPrefix(err.Error(), "ligne 3 : ") {
+			t.Errorf("ParseHostLimits(%q) : %v, numéro de ligne attendu", invalid, err)
+		}
+	}
+}
+
+func TestHostLimitsForHost(t *testing.T) {
+	var limits hostLimits
+	limits.set(HostLimit{MaxDownloads: 2, MaxConnections: 4}, []HostLimit{
+		{Pattern: "files.example.com", MaxDownloads: 1, MaxConnections: 1},
+		{Pattern: "*.example.com", MaxDownloads: 3, MaxConnections: 6},
+	})
+
+	tests := map[string]int{
+		"files.example.com": 1, // La première règle qui correspond l'emporte
+		"www.example.com":   3,
+		"example.com":       2,
+		"autre.org":         2,
+	}
+	for host, want := range tests {
+		if got := limits.forHost(host).MaxDownloads; got != want {
+			t.Errorf("forHost(%q).MaxDownloads = %d, attendu %d", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// En-têtes positionnés par le gestionnaire lui-même, qui ne peuvent pas être remplacés
+var managedHeaders = []string{"Range", "If-Range", "Cookie", "Content-Length", "Transfer-Encoding", "Host"}
+
+// HTTPOptions complète les requêtes d'un téléchargement : en-têtes arbitraires,
+// cookies de session, User-Agent et Referer. Elles s'appliquent à toutes les
+// requêtes du téléchargement, y compris après une reprise.
+type HTTPOptions struct {
+	Headers   http.Header
+	Cookies   []*http.Cookie
+	UserAgent string
+	Referer   string
+}
+
+// apply ajoute les options à la requête ; les en-têtes propres au téléchargement
+// (Range...) sont positionnés ensuite et ne peuvent pas être écrasés
+func (o HTTPOptions) apply(req *http.Request) {
+	for name, values := range o.Headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if o.UserAgent != "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	}
+	if o.Referer != "" {
+		req.Header.Set("Referer", o.Referer)
+	}
+}
+
+// ParseHeaders lit un en-tête par ligne au format "Nom: valeur" ; les lignes
+// vides et celles commençant par # sont ignorées
+func ParseHeaders(text string) (http.Header, error) {
+	headers := http.Header{}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(raw, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("ligne %d : format attendu « Nom: valeur »", line)
+		}
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		for _, managed := range managedHeaders {
+			if name == managed {
+				return nil, fmt.Errorf("ligne %d : l'en-tête %s est géré par le gestionnaire", line, name)
+			}
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+
+	return headers, nil
+}
+
+// FormatHeaders est l'inverse de ParseHeaders
+func FormatHeaders(headers http.Header) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		for _, value := range headers[name] {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseCookies lit des cookies au format de l'en-tête Cookie : "nom=valeur; nom2=valeur2",
+// les retours à la ligne étant acceptés comme séparateurs
+func ParseCookies(text string) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	for _, pair := range strings.FieldsFunc(text, func(r rune) bool { return r == ';' || r == '\n' }) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t,") {
+			return nil, fmt.Errorf("cookie invalide : %s", pair)
+		}
+		cookie := &http.Cookie{Name: name, Value: strings.TrimSpace(value)}
+		if err := cookie.Valid(); err != nil {
+			return nil, fmt.Errorf("cookie invalide : %s", pair)
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies, nil
+}
+
+// FormatCookies est l'inverse de ParseCookies
+func FormatCookies(cookies []*http.Cookie) string {
+	pairs := make([]string, len(cookies))
+	for i, cookie := range cookies {
+		pairs[i] = cookie.Name + "=" + cookie.Value
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// newCookieJar crée le cookie jar d'un téléchargement, initialisé avec les
+// cookies saisis pour l'hôte de l'URL ; ceux posés par le serveur, lors d'une
+// redirection ou de la requête d'information, sont renvoyés aux requêtes suivantes
+func newCookieJar(rawURL string, cookies []*http.Cookie) http.CookieJar {
+	jar, _ := cookiejar.New(nil) // cookiejar.New n'échoue jamais sans options
+	u, err := url.Parse(rawURL)
+	if err != nil || len(cookies) == 0 {
+		return jar
+	}
+
+	seeded := make([]*http.Cookie, len(cookies))
+	for i, cookie := range cookies {
+		// Sans domaine, le cookie n'est envoyé qu'à cet hôte ; valable pour tous ses chemins
+		c := *cookie
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		seeded[i] = &c
+	}
+	jar.SetCookies(u, seeded)
+	return jar
+}
+
+// newRequest crée une requête du téléchargement, avec ses options HTTP
+func (d *Downloader) newRequest(ctx context.Context, j *job, method, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de créer la requête : %v", err)
+	}
+	j.Options.apply(req)
+	return req, nil
+}
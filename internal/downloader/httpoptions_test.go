@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		text string
+		want http.Header
+		err  bool
+	}{
+		{"", http.Header{}, false},
+		{"x-api-key: secret", http.Header{"X-Api-Key": {"secret"}}, false},
+		{"# commentaire\n\nAccept:  text/plain \nAccept: */*", http.Header{"Accept": {"text/plain", "*/*"}}, false},
+		{"Authorization: Bearer a:b", http.Header{"Authorization": {"Bearer a:b"}}, false},
+		{"X-Vide:", http.Header{"X-Vide": {""}}, false},
+		{"sans deux-points", nil, true},
+		{": valeur", nil, true},
+		{"Nom Invalide: valeur", nil, true},
+		{"range: bytes=0-", nil, true}, // En-tête géré par le gestionnaire
+		{"Cookie: a=b", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHeaders(tt.text)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseHeaders(%q) = %v, %v ; attendu %v, erreur %v", tt.text, got, err, tt.want, tt.err)
+		}
+		if err == nil {
+			if again, _ := ParseHeaders(FormatHeaders(got)); !reflect.DeepEqual(again, got) {
+				t.Errorf("ParseHeaders(FormatHeaders(%v)) = %v", got, again)
+			}
+		}
+	}
+}
+
+func TestParseCookies(t *testing.T) {
+	tests := []struct {
+		text string
+		want string // Cookies relus, au format de FormatCookies
+		err  bool
+	}{
+		{"", "", false},
+		{"session=abc", "session=abc", false},
+		{" a=1 ;b=2;\nc = 3 ", "a=1; b=2; c=3", false},
+		{"vide=", "vide=", false},
+		{"sans-egal", "", true},
+		{"=valeur", "", true},
+		{"nom invalide=1", "", true},
+	}
+	for _, tt := range tests {
+		cookies, err := ParseCookies(tt.text)
+		if got := FormatCookies(cookies); (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseCookies(%q) = %q, %v ; attendu %q, erreur %v", tt.text, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// Les options HTTP accompagnent toutes les requêtes du téléchargement, requêtes
+// Range comprises
+func TestHTTPOptionsSent(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+		http.ServeContent(w, r, "", time.Unix(0, 0), bytes.NewReader(testData))
+	}))
+	t.Cleanup(server.Close)
+
+	headers, err := ParseHeaders("X-Api-Key: secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, err := ParseCookies("session=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newTestDownloader(t)
+	req := Request{URL: server.URL + "/file.bin", Options: HTTPOptions{
+		Headers:   headers,
+		Cookies:   cookies,
+		UserAgent: "agent-de-test/1.0",
+		Referer:   "http://example.com/page",
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := d.DownloadContext(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) < 2 {
+		t.Fatalf("%d requêtes reçues, attendu au moins la requête HEAD et une requête GET", len(requests))
+	}
+	for _, r := range requests {
+		cookie, err := r.Cookie("session")
+		if r.Header.Get("X-Api-Key") != "secret" || r.UserAgent() != "agent-de-test/1.0" || r.Referer() != "http://example.com/page" || err != nil || cookie.Value != "abc" {
+			t.Errorf("requête %s %q sans les options : %v", r.Method, r.Header.Get("Range"), r.Header)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Causes d'interruption transmises via le contexte du téléchargement
+var (
+	ErrCancelled     = errors.New("téléchargement annulé")
+	ErrPaused        = errors.New("téléchargement mis en pause")
+	ErrDeleted       = errors.New("téléchargement supprimé")
+	ErrShutdown      = errors.New("gestionnaire de téléchargement arrêté")
+	ErrAlreadyActive = errors.New("téléchargement déjà en cours")
+)
+
+// closedDone est retourné par stopJob lorsqu'aucun téléchargement n'est actif
+var closedDone = func() chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}()
+
+// job représente un téléchargement en cours ou en attente d'une place
+type job struct {
+	Request
+	cancel  context.CancelCauseFunc
+	stop    func() bool
+	done    chan struct{}
+	retries atomic.Int64 // Nouvelles tentatives effectuées, toutes sessions confondues
+	retry   RetryPolicy  // Politique de reprise en vigueur au démarrage du job
+	limiter *RateLimiter // Débit maximal propre à ce téléchargement
+	entry   *queueEntry  // Place du job dans la file d'attente
+	result  error        // Issue du téléchargement, lisible une fois done fermé
+	host    string       // Hôte de l'URL, auquel s'appliquent les limites par hôte
+	client  *http.Client // Client propre au téléchargement, avec son cookie jar et ses identifiants
+	// Avancement de la session en cours, nil tant que la taille n'est pas connue
+	progress atomic.Pointer[downloadProgress]
+}
+
+// progressEvent retourne un événement portant l'avancement du job
+func (j *job) progressEvent(eventType EventType) Event {
+	event := Event{Type: eventType, ID: j.ID}
+	if progress := j.progress.Load(); progress != nil {
+		event.Downloaded, event.Total = progress.bytes()
+	}
+	return event
+}
+
+// emitProgress publie un événement portant l'avancement du job
+func (d *Downloader) emitProgress(eventType EventType, j *job) {
+	d.emit(j.progressEvent(eventType))
+}
+
+// IsInterrupted indique si err résulte d'une pause, d'une annulation, d'une
+// suppression ou d'un arrêt demandé, et non d'un échec du téléchargement
+func IsInterrupted(err error) bool {
+	return errors.Is(err, ErrPaused) || errors.Is(err, ErrCancelled) || errors.Is(err, ErrDeleted) || errors.Is(err, ErrShutdown)
+}
+
+// startJob enregistre le téléchargement et retourne son contexte, annulé dès que
+// ctx, l'arrêt du Downloader ou stopJob le demandent
+func (d *Downloader) startJob(ctx context.Context, req Request) (context.Context, *job, error) {
+	if d.ctx.Err() != nil {
+		return nil, nil, context.Cause(d.ctx)
+	}
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	j := &job{
+		Request: req,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		limiter: NewRateLimiter(req.SpeedLimit),
+		retry:   d.RetryPolicy(),
+		host:    hostOf(req.URL),
+	}
+	// Le client du Downloader, avec les cookies et l'authentification du téléchargement
+	client := *d.client
+	client.Jar = newCookieJar(req.URL, req.Options.Cookies)
+	client.Transport = newAuthTransport(d.client.Transport, d.credentialsLookup(req))
+	j.client = &client
+
+	if _, exists := d.jobs.LoadOrStore(req.ID, j); exists {
+		cancel(ErrAlreadyActive)
+		return nil, nil, fmt.Errorf("%w : %d", ErrAlreadyActive, req.ID)
+	}
+
+	// Propager l'arrêt global au téléchargement
+	j.stop = context.AfterFunc(d.ctx, func() {
+		cancel(context.Cause(d.ctx))
+	})
+	d.wg.Add(1)
+
+	return jobCtx, j, nil
+}
+
+// finishJob libère le téléchargement, terminé avec l'erreur err, et débloque
+// ceux qui attendent sa fin
+func (d *Downloader) finishJob(j *job, err error) {
+	d.jobs.CompareAndDelete(j.ID, j)
+	j.stop()
+	j.cancel(nil)
+	j.result = err
+	if err != nil && !IsInterrupted(err) {
+		d.emit(Event{Type: EventFailed, ID: j.ID, Err: err})
+	}
+	close(j.done)
+	d.wg.Done()
+}
+
+// stopJob interrompt le téléchargement avec la cause donnée et retourne un canal
+// fermé une fois que le téléchargement a libéré ses ressources
+func (d *Downloader) stopJob(id int64, cause error) <-chan struct{} {
+	value, ok := d.jobs.Load(id)
+	if !ok {
+		return closedDone
+	}
+
+	j := value.(*job)
+	j.cancel(cause)
+	return j.done
+}
+
+// Shutdown interrompt tous les téléchargements et attend qu'ils aient enregistré
+// leur avancement ; ils pourront être repris au prochain lancement
+func (d *Downloader) Shutdown() {
+	d.stop(ErrShutdown)
+	d.wg.Wait()
+	d.eventSubscribers.close()
+}
@@ -0,0 +1,112 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// Une reprise impossible laisse le téléchargement en pause, sans l'annoncer repris
+func TestResumeFailureKeepsPaused(t *testing.T) {
+	d := newTestDownloader(t)
+	sub := d.Subscribe(DefaultEventBuffer)
+	d.emit(Event{Type: EventPaused, ID: 1})
+
+	// Sans Store ni état enregistré, le téléchargement ne peut pas être repris
+	if err := d.ResumeDownload(1); err == nil {
+		t.Fatal("reprise d'un téléchargement sans état acceptée")
+	}
+	if status, _ := d.status(1); status != StatusPaused {
+		t.Errorf("statut %q après l'échec de la reprise, attendu %q", status, StatusPaused)
+	}
+
+	sub.Close()
+	for event := range sub.Events() {
+		if event.Type == EventResumed {
+			t.Errorf("événement %v publié malgré l'échec de la reprise", event)
+		}
+	}
+}
+
+// Annuler le contexte de DownloadContext interrompt le transfert en cours et
+// retourne la cause de l'annulation ; le téléchargement peut ensuite être relancé
+func TestDownloadContextCancel(t *testing.T) {
+	server := startRangeServer(t)
+	release := server.hold()
+	defer release()
+	d := newTestDownloader(t)
+	sub := d.Subscribe(DefaultEventBuffer)
+	defer sub.Close()
+
+	req, err := d.Add(server.URL + "/file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("arrêt demandé par l'appelant")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- d.DownloadContext(ctx, req) }()
+
+	waitEvent(t, sub, req.ID, EventStarted)
+	cancel(stop)
+	if err := <-result; !errors.Is(err, stop) {
+		t.Fatalf("DownloadContext : %v, attendu la cause de l'annulation", err)
+	}
+	if _, active := d.jobs.Load(req.ID); active {
+		t.Fatal("job toujours enregistré après l'annulation")
+	}
+
+	release()
+	if err := d.DownloadContext(context.Background(), req); err != nil {
+		t.Errorf("nouveau téléchargement après l'annulation : %v", err)
+	}
+}
+
+// La pause libère la place dans la file : le téléchargement suivant démarre. Une
+// reprise demandée deux fois ne crée qu'un seul job.
+func TestPauseReleasesSlot(t *testing.T) {
+	server := startRangeServer(t)
+	release := server.hold()
+	defer release()
+	d := newTestDownloader(t)
+	d.Store = newMemoryStore()
+	d.SetMaxConcurrent(1)
+	sub := d.Subscribe(DefaultEventBuffer)
+	defer sub.Close()
+
+	// Le premier téléchargement occupe l'unique place, le second attend
+	start := func() int64 {
+		req, err := d.Add(server.URL + "/file.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go d.DownloadContext(context.Background(), req)
+		return req.ID
+	}
+	first := start()
+	waitEvent(t, sub, first, EventStarted)
+	second := start()
+
+	if err := d.PauseDownload(first); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, sub, second, EventStarted)
+	release()
+	waitEvent(t, sub, second, EventCompleted)
+
+	for range 2 {
+		if err := d.ResumeDownload(first); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counts := make(map[EventType]int)
+	for event := waitEvent(t, sub, first, EventResumed); event.Type != EventCompleted; event = waitEvent(t, sub, first, EventStarted, EventCompleted, EventFailed) {
+		if event.Type == EventFailed {
+			t.Fatalf("reprise : %v", event)
+		}
+		counts[event.Type]++
+	}
+	if counts[EventResumed] != 1 || counts[EventStarted] != 1 {
+		t.Errorf("%d reprises et %d démarrages publiés, attendu un seul job", counts[EventResumed], counts[EventStarted])
+	}
+}
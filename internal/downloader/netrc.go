@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// netrcPath retourne le chemin du fichier .netrc : $NETRC, sinon ~/.netrc
+// (~/_netrc sous Windows)
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// netrcCredentials cherche les identifiants de host dans le fichier .netrc ; la
+// section "default" s'applique aux hôtes sans section "machine"
+func netrcCredentials(host string) (Credentials, bool) {
+	path := netrcPath()
+	if path == "" {
+		return Credentials{}, false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Credentials{}, false
+	}
+	defer f.Close()
+
+	creds, def, found, hasDefault := parseNetrc(bufio.NewScanner(f), host)
+	if found {
+		return creds, true
+	}
+	return def, hasDefault
+}
+
+// parseNetrc lit les jetons du fichier ; le contenu des macros (macdef), qui
+// s'étend jusqu'à la prochaine ligne vide, est ignoré
+func parseNetrc(scanner *bufio.Scanner, host string) (creds, def Credentials, found, hasDefault bool) {
+	var current *Credentials
+	inMacro := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			next := func() string {
+				if i+1 < len(fields) {
+					i++
+					return fields[i]
+				}
+				return ""
+			}
+
+			switch fields[i] {
+			case "machine":
+				if found {
+					// La première section de l'hôte l'emporte
+					return creds, def, found, hasDefault
+				}
+				current = nil
+				if strings.EqualFold(next(), host) {
+					found = true
+					current = &creds
+				}
+			case "default":
+				if found {
+					return creds, def, found, hasDefault
+				}
+				hasDefault = true
+				current = &def
+			case "login":
+				if value := next(); current != nil {
+					current.Username = value
+				}
+			case "password":
+				if value := next(); current != nil {
+					current.Password = value
+				}
+			case "account":
+				next()
+			case "macdef":
+				next()
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return creds, def, found, hasDefault
+}
@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	const netrc = `# Identifiants de test
+machine example.com login alice password secret1
+machine other.org
+	login bob
+	password secret2
+	account ignoré
+
+macdef init
+machine example.net login macro password caché
+
+default login anonymous password guest@
+machine example.com login second password ignoré
+`
+	tests := []struct {
+		host  string
+		creds Credentials
+		found bool
+	}{
+		{"example.com", Credentials{Username: "alice", Password: "secret1"}, true},
+		{"EXAMPLE.COM", Credentials{Username: "alice", Password: "secret1"}, true},
+		{"other.org", Credentials{Username: "bob", Password: "secret2"}, true},
+		// Le contenu d'une macro n'est pas une section
+		{"example.net", Credentials{}, false},
+		{"inconnu.test", Credentials{}, false},
+	}
+	for _, tt := range tests {
+		creds, def, found, hasDefault := parseNetrc(bufio.NewScanner(strings.NewReader(netrc)), tt.host)
+		if creds != tt.creds || found != tt.found {
+			t.Errorf("parseNetrc(%q) = %+v, %v ; attendu %+v, %v", tt.host, creds, found, tt.creds, tt.found)
+		}
+		if !found && (!hasDefault || def != (Credentials{Username: "anonymous", Password: "guest@"})) {
+			t.Errorf("parseNetrc(%q) : section default %+v, %v", tt.host, def, hasDefault)
+		}
+	}
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	t.Setenv("NETRC", path)
+
+	if _, ok := netrcCredentials("example.com"); ok {
+		t.Error("identifiants trouvés sans fichier .netrc")
+	}
+
+	if err := os.WriteFile(path, []byte("machine example.com login alice password secret\ndefault login anonymous\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if creds, ok := netrcCredentials("example.com"); !ok || creds.Username != "alice" || creds.Password != "secret" {
+		t.Errorf("example.com : %+v, %v", creds, ok)
+	}
+	if creds, ok := netrcCredentials("ailleurs.org"); !ok || creds.Username != "anonymous" {
+		t.Errorf("section default : %+v, %v", creds, ok)
+	}
+}
@@ -0,0 +1,254 @@
+package downloader
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyDirect désactive le proxy, y compris celui de l'environnement
+const ProxyDirect = "direct"
+
+// ProxyRule fait passer les requêtes vers les hôtes correspondant au motif par
+// un autre proxy que le proxy global
+type ProxyRule struct {
+	Pattern string   // Nom d'hôte, éventuellement avec jokers : "*.example.org"
+	Proxy   *url.URL // nil pour une connexion directe
+}
+
+// ParseProxyURL lit l'adresse d'un proxy : "http://", "https://", "socks5://" ou
+// "socks5h://", avec identifiants facultatifs ("socks5://user:pass@hôte:1080") ;
+// ProxyDirect retourne nil
+func ParseProxyURL(text string) (*url.URL, error) {
+	text = strings.TrimSpace(text)
+	if strings.EqualFold(text, ProxyDirect) {
+		return nil, nil
+	}
+
+	u, err := url.Parse(text)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("adresse de proxy invalide : %s", redactProxy(text))
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("type de proxy non supporté : %s", u.Scheme)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("adresse de proxy invalide : %s", u.Redacted())
+	}
+	u.Path = ""
+	return u, nil
+}
+
+// redactProxy masque le mot de passe d'une adresse de proxy, même invalide
+func redactProxy(text string) string {
+	if at := strings.LastIndex(text, "@"); at >= 0 {
+		if scheme := strings.Index(text, "://"); scheme >= 0 && scheme < at {
+			return text[:scheme+3] + "***" + text[at:]
+		}
+		return "***" + text[at:]
+	}
+	return text
+}
+
+// ParseProxyRules lit une règle par ligne au format "motif proxy", le proxy étant
+// une adresse acceptée par ParseProxyURL ou ProxyDirect ; les lignes vides et
+// celles commençant par # sont ignorées
+func ParseProxyRules(text string) ([]ProxyRule, error) {
+	var rules []ProxyRule
+
+	err := scanLines(text, func(line int, fields []string) error {
+		if len(fields) != 2 {
+			return fmt.Errorf("ligne %d : format attendu « motif proxy »", line)
+		}
+
+		pattern, err := parsePattern(fields[0])
+		if err != nil {
+			return fmt.Errorf("ligne %d : %v", line, err)
+		}
+		proxyURL, err := ParseProxyURL(fields[1])
+		if err != nil {
+			return fmt.Errorf("ligne %d : %v", line, err)
+		}
+
+		rules = append(rules, ProxyRule{Pattern: pattern, Proxy: proxyURL})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// FormatProxyRules est l'inverse de ParseProxyRules
+func FormatProxyRules(rules []ProxyRule) string {
+	lines := make([]string, len(rules))
+	for i, rule := range rules {
+		target := ProxyDirect
+		if rule.Proxy != nil {
+			target = rule.Proxy.String()
+		}
+		lines[i] = rule.Pattern + " " + target
+	}
+	return strings.Join(lines, "\n")
+}
+
+// proxySettings choisit le proxy d'une requête : première règle dont le motif
+// correspond à l'hôte, sinon proxy global, sinon celui de l'environnement
+// (HTTP_PROXY, HTTPS_PROXY, NO_PROXY)
+type proxySettings struct {
+	mu             sync.RWMutex
+	global         *url.URL
+	useEnvironment bool
+	rules          []ProxyRule
+}
+
+func (p *proxySettings) set(global *url.URL, useEnvironment bool, rules []ProxyRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.global = global
+	p.useEnvironment = useEnvironment
+	p.rules = rules
+}
+
+func (p *proxySettings) forRequest(req *http.Request) (*url.URL, error) {
+	host := strings.ToLower(req.URL.Hostname())
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, rule := range p.rules {
+		if matched, _ := path.Match(rule.Pattern, host); matched {
+			return rule.Proxy, nil
+		}
+	}
+	if p.useEnvironment {
+		return http.ProxyFromEnvironment(req)
+	}
+	return p.global, nil
+}
+
+// SetProxy remplace la configuration du proxy ; global nil avec useEnvironment
+// reprend le proxy de l'environnement, sans lui une connexion directe. Les
+// requêtes suivantes l'appliquent, y compris celles des téléchargements en cours.
+func (d *Downloader) SetProxy(global *url.URL, useEnvironment bool, rules []ProxyRule) {
+	d.proxies.set(global, useEnvironment, rules)
+	d.transport.closeIdleConnections()
+}
+
+// proxyTransport envoie chaque requête par le transport du proxy qui lui est
+// attribué ; les transports, et leurs connexions, sont conservés par proxy et
+// par hôte, chacun avec la configuration TLS de son hôte
+type proxyTransport struct {
+	proxies    *proxySettings
+	tls        *tlsSettings
+	mu         sync.Mutex
+	transports map[transportKey]*http.Transport
+}
+
+// transportKey identifie un transport : adresse du proxy, vide pour une connexion
+// directe, et hôte contacté
+type transportKey struct {
+	proxy string
+	host  string
+}
+
+func newProxyTransport(proxies *proxySettings, tlsConfig *tlsSettings) *proxyTransport {
+	return &proxyTransport{proxies: proxies, tls: tlsConfig, transports: make(map[transportKey]*http.Transport)}
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxyURL, err := t.proxies.forRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de déterminer le proxy : %v", err)
+	}
+	transport, err := t.transport(proxyURL, strings.ToLower(req.URL.Hostname()))
+	if err != nil {
+		return nil, err
+	}
+	return transport.RoundTrip(req)
+}
+
+func (t *proxyTransport) transport(proxyURL *url.URL, host string) (*http.Transport, error) {
+	key := transportKey{host: host}
+	if proxyURL != nil {
+		key.proxy = proxyURL.String()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if transport, ok := t.transports[key]; ok {
+		return transport, nil
+	}
+	transport, err := newProxiedTransport(proxyURL, t.tls.get().config(host))
+	if err != nil {
+		return nil, err
+	}
+	t.transports[key] = transport
+	return transport, nil
+}
+
+// closeIdleConnections oublie les transports, dont le proxy ou la configuration
+// TLS ne sont peut-être plus à jour, et ferme leurs connexions inutilisées
+func (t *proxyTransport) closeIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, transport := range t.transports {
+		transport.CloseIdleConnections()
+		delete(t.transports, key)
+	}
+}
+
+// newProxiedTransport crée le transport d'un proxy : les proxys HTTP et HTTPS
+// sont gérés par http.Transport (tunnel CONNECT pour les URLs https), les proxys
+// SOCKS5 par la connexion elle-même
+func newProxiedTransport(proxyURL *url.URL, tlsConfig *tls.Config) (*http.Transport, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if proxyURL == nil {
+		return transport, nil
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, fmt.Errorf("proxy SOCKS5 invalide %s : %v", proxyURL.Redacted(), err)
+		}
+		contextDialer, ok := socks.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("proxy SOCKS5 invalide %s", proxyURL.Redacted())
+		}
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := contextDialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, fmt.Errorf("proxy %s : %v", proxyURL.Redacted(), err)
+			}
+			return conn, nil
+		}
+	default:
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
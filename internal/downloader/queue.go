@@ -0,0 +1,303 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+)
+
+// Priority ordonne les téléchargements en attente : une priorité plus haute passe devant
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// QueueMove décrit un déplacement dans la file d'attente
+type QueueMove int
+
+const (
+	MoveUp QueueMove = iota
+	MoveDown
+	MoveToTop
+	MoveToBottom
+)
+
+// queueEntry est un téléchargement en attente d'une place ; ready est fermé
+// lorsque la place lui est attribuée
+type queueEntry struct {
+	id       int64
+	host     string
+	priority Priority
+	ready    chan struct{}
+}
+
+// downloadQueue attribue les places de téléchargement dans l'ordre de la file.
+// Les places sont comptées et non stockées dans un canal : changer la limite ne
+// fait ni perdre ni dupliquer de place, une baisse prenant effet à mesure que les
+// téléchargements actifs se terminent. Une entrée dont l'hôte a atteint sa
+// limite laisse passer les suivantes sans perdre sa place.
+type downloadQueue struct {
+	mu         sync.Mutex
+	waiting    []*queueEntry
+	active     int
+	limit      int
+	hostActive map[string]int
+	hostLimit  func(host string) int // Téléchargements simultanés autorisés par hôte, 0 si illimité
+	onChange   func(order []int64)
+
+	// Dernier ordre à publier, relevé sous mu et publié par publish hors du verrou
+	pending    []int64
+	hasPending bool
+	publishMu  sync.Mutex // Publie les ordres un à un, le plus récent en dernier
+}
+
+func newDownloadQueue(limit int) *downloadQueue {
+	return &downloadQueue{limit: limit, hostActive: make(map[string]int)}
+}
+
+// push ajoute id derrière les entrées de priorité supérieure ou égale
+func (q *downloadQueue) push(id int64, host string, priority Priority) *queueEntry {
+	defer q.publish()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := &queueEntry{id: id, host: host, priority: priority, ready: make(chan struct{})}
+	q.insertLocked(e)
+	q.dispatchLocked()
+	q.changedLocked()
+	return e
+}
+
+func (q *downloadQueue) insertLocked(e *queueEntry) {
+	i := len(q.waiting)
+	for i > 0 && q.waiting[i-1].priority < e.priority {
+		i--
+	}
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[i+1:], q.waiting[i:])
+	q.waiting[i] = e
+}
+
+// wait bloque jusqu'à ce qu'une place soit attribuée à e ou que ctx soit annulé ;
+// l'entrée est alors retirée de la file
+func (q *downloadQueue) wait(ctx context.Context, e *queueEntry) error {
+	select {
+	case <-e.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	if q.indexLocked(e.id) >= 0 {
+		q.removeLocked(e.id)
+		q.changedLocked()
+		q.mu.Unlock()
+		q.publish()
+		return context.Cause(ctx)
+	}
+	q.mu.Unlock()
+
+	// La place a été attribuée en même temps que l'annulation : la rendre
+	q.release(e)
+	return context.Cause(ctx)
+}
+
+// release rend la place de e et la donne à la première entrée qui peut démarrer
+func (q *downloadQueue) release(e *queueEntry) {
+	defer q.publish()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.active--
+	if q.hostActive[e.host]--; q.hostActive[e.host] <= 0 {
+		delete(q.hostActive, e.host)
+	}
+	if q.dispatchLocked() {
+		q.changedLocked()
+	}
+}
+
+// redispatch réévalue la file après un changement des limites par hôte
+func (q *downloadQueue) redispatch() {
+	defer q.publish()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.dispatchLocked() {
+		q.changedLocked()
+	}
+}
+
+// hostFullLocked indique si l'hôte a atteint son nombre de téléchargements simultanés
+func (q *downloadQueue) hostFullLocked(host string) bool {
+	if q.hostLimit == nil {
+		return false
+	}
+	limit := q.hostLimit(host)
+	return limit > 0 && q.hostActive[host] >= limit
+}
+
+// dispatchLocked attribue les places libres aux premières entrées dont l'hôte
+// n'est pas saturé et indique si la file a changé
+func (q *downloadQueue) dispatchLocked() bool {
+	changed := false
+	for i := 0; q.active < q.limit && i < len(q.waiting); {
+		e := q.waiting[i]
+		if q.hostFullLocked(e.host) {
+			i++
+			continue
+		}
+		q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+		q.active++
+		q.hostActive[e.host]++
+		close(e.ready)
+		changed = true
+	}
+	return changed
+}
+
+// setLimit modifie le nombre de places ; les places libérées sont attribuées aussitôt
+func (q *downloadQueue) setLimit(limit int) {
+	defer q.publish()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.limit = limit
+	if q.dispatchLocked() {
+		q.changedLocked()
+	}
+}
+
+func (q *downloadQueue) indexLocked(id int64) int {
+	for i, e := range q.waiting {
+		if e.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *downloadQueue) removeLocked(id int64) *queueEntry {
+	i := q.indexLocked(id)
+	if i < 0 {
+		return nil
+	}
+	e := q.waiting[i]
+	q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+	return e
+}
+
+// move déplace une entrée en attente ; false si id n'est pas dans la file
+func (q *downloadQueue) move(id int64, move QueueMove) bool {
+	defer q.publish()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.indexLocked(id)
+	if i < 0 {
+		return false
+	}
+
+	target := i
+	switch move {
+	case MoveUp:
+		target = max(i-1, 0)
+	case MoveDown:
+		target = min(i+1, len(q.waiting)-1)
+	case MoveToTop:
+		target = 0
+	case MoveToBottom:
+		target = len(q.waiting) - 1
+	}
+	if target == i {
+		return true
+	}
+
+	e := q.removeLocked(id)
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[target+1:], q.waiting[target:])
+	q.waiting[target] = e
+	q.changedLocked()
+	return true
+}
+
+// setPriority change la priorité d'une entrée en attente et la replace en conséquence
+func (q *downloadQueue) setPriority(id int64, priority Priority) bool {
+	defer q.publish()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.removeLocked(id)
+	if e == nil {
+		return false
+	}
+	e.priority = priority
+	q.insertLocked(e)
+	q.changedLocked()
+	return true
+}
+
+func (q *downloadQueue) orderLocked() []int64 {
+	order := make([]int64, len(q.waiting))
+	for i, e := range q.waiting {
+		order[i] = e.id
+	}
+	return order
+}
+
+func (q *downloadQueue) order() []int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.orderLocked()
+}
+
+// changedLocked relève le nouvel ordre, que publish diffusera une fois le verrou
+// rendu : un abonné lent ne doit pas bloquer l'attribution des places
+func (q *downloadQueue) changedLocked() {
+	q.pending, q.hasPending = q.orderLocked(), true
+}
+
+// publish diffuse le dernier ordre relevé ; un ordre déjà remplacé par un plus
+// récent n'est pas publié
+func (q *downloadQueue) publish() {
+	q.publishMu.Lock()
+	defer q.publishMu.Unlock()
+
+	q.mu.Lock()
+	order, changed := q.pending, q.hasPending
+	q.pending, q.hasPending = nil, false
+	q.mu.Unlock()
+
+	if changed && q.onChange != nil {
+		q.onChange(order)
+	}
+}
+
+// SetMaxConcurrent modifie le nombre de téléchargements simultanés sans
+// interrompre ceux en cours
+func (d *Downloader) SetMaxConcurrent(n int) {
+	if n < 1 {
+		n = 1
+	}
+	d.MaxConcurrent = n
+	d.queue.setLimit(n)
+}
+
+// QueueOrder retourne les IDs des téléchargements en attente d'une place, dans l'ordre
+func (d *Downloader) QueueOrder() []int64 {
+	return d.queue.order()
+}
+
+// MoveInQueue déplace un téléchargement en attente ; false s'il n'attend pas de place
+func (d *Downloader) MoveInQueue(id int64, move QueueMove) bool {
+	return d.queue.move(id, move)
+}
+
+// SetPriority change la priorité d'un téléchargement en attente ; false s'il
+// n'attend pas de place, la priorité enregistrée s'appliquera alors à sa reprise
+func (d *Downloader) SetPriority(id int64, priority Priority) bool {
+	return d.queue.setPriority(id, priority)
+}
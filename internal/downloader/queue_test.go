@@ -0,0 +1,126 @@
+package downloader
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// newWaitingQueue retourne une file sans place libre contenant les IDs donnés,
+// tous de priorité normale
+func newWaitingQueue(ids ...int64) *downloadQueue {
+	q := newDownloadQueue(0)
+	for _, id := range ids {
+		q.push(id, "example.com", PriorityNormal)
+	}
+	return q
+}
+
+func TestQueuePushPriority(t *testing.T) {
+	q := newDownloadQueue(0)
+	q.push(1, "example.com", PriorityNormal)
+	q.push(2, "example.com", PriorityLow)
+	q.push(3, "example.com", PriorityHigh)
+	q.push(4, "example.com", PriorityNormal)
+	q.push(5, "example.com", PriorityHigh)
+
+	if got, want := q.order(), []int64{3, 5, 1, 4, 2}; !slices.Equal(got, want) {
+		t.Errorf("ordre %v, attendu %v", got, want)
+	}
+}
+
+func TestQueueMove(t *testing.T) {
+	tests := []struct {
+		id   int64
+		move QueueMove
+		ok   bool
+		want []int64
+	}{
+		{3, MoveUp, true, []int64{1, 3, 2, 4}},
+		{1, MoveUp, true, []int64{1, 2, 3, 4}},
+		{2, MoveDown, true, []int64{1, 3, 2, 4}},
+		{4, MoveDown, true, []int64{1, 2, 3, 4}},
+		{3, MoveToTop, true, []int64{3, 1, 2, 4}},
+		{2, MoveToBottom, true, []int64{1, 3, 4, 2}},
+		{9, MoveToTop, false, []int64{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		q := newWaitingQueue(1, 2, 3, 4)
+		ok := q.move(tt.id, tt.move)
+		if got := q.order(); ok != tt.ok || !slices.Equal(got, tt.want) {
+			t.Errorf("move(%d, %d) = %v, ordre %v ; attendu %v, %v", tt.id, tt.move, ok, got, tt.ok, tt.want)
+		}
+	}
+}
+
+func TestQueueSetPriority(t *testing.T) {
+	tests := []struct {
+		id       int64
+		priority Priority
+		ok       bool
+		want     []int64
+	}{
+		{3, PriorityHigh, true, []int64{3, 1, 2, 4}},
+		{1, PriorityLow, true, []int64{2, 3, 4, 1}},
+		// Une entrée qui garde sa priorité passe derrière celles de même priorité
+		{2, PriorityNormal, true, []int64{1, 3, 4, 2}},
+		{9, PriorityHigh, false, []int64{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		q := newWaitingQueue(1, 2, 3, 4)
+		ok := q.setPriority(tt.id, tt.priority)
+		if got := q.order(); ok != tt.ok || !slices.Equal(got, tt.want) {
+			t.Errorf("setPriority(%d, %d) = %v, ordre %v ; attendu %v, %v", tt.id, tt.priority, ok, got, tt.ok, tt.want)
+		}
+	}
+
+	// L'insertion part de la fin de la file : une entrée placée en tête à la main y reste
+	q := newWaitingQueue(1, 2, 3)
+	q.setPriority(1, PriorityHigh)
+	q.move(3, MoveToTop)
+	q.setPriority(2, PriorityHigh)
+	if got, want := q.order(), []int64{3, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("ordre %v, attendu %v", got, want)
+	}
+}
+
+// Une entrée dont l'hôte est saturé laisse passer les suivantes sans perdre sa place
+func TestQueueHostLimit(t *testing.T) {
+	q := newDownloadQueue(2)
+	q.hostLimit = func(host string) int { return 1 }
+
+	a := q.push(1, "a.example.com", PriorityNormal)
+	q.push(2, "a.example.com", PriorityNormal)
+	c := q.push(3, "b.example.com", PriorityNormal)
+
+	ctx := context.Background()
+	if err := q.wait(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.wait(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.order(), []int64{2}; !slices.Equal(got, want) {
+		t.Errorf("ordre %v, attendu %v", got, want)
+	}
+}
+
+// L'ordre est publié hors du verrou : l'abonné peut relire la file
+func TestQueueOnChange(t *testing.T) {
+	q := newDownloadQueue(0)
+	var published [][]int64
+	q.onChange = func(order []int64) {
+		published = append(published, order)
+		q.order()
+	}
+
+	q.push(1, "example.com", PriorityNormal)
+	q.push(2, "example.com", PriorityNormal)
+	q.move(2, MoveToTop)
+	q.setLimit(1)
+
+	want := [][]int64{{1}, {1, 2}, {2, 1}, {1}}
+	if !slices.EqualFunc(published, want, slices.Equal[[]int64]) {
+		t.Errorf("ordres publiés %v, attendu %v", published, want)
+	}
+}
@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Taille maximale d'une lecture limitée, pour lisser le débit
+const limitedReadSize = 16 * 1024
+
+// Attente maximale avant de réévaluer le débit, qui peut changer à tout moment
+const maxLimiterWait = 100 * time.Millisecond
+
+// RateLimiter est un seau à jetons dont le débit, en octets par seconde, peut être
+// modifié pendant les téléchargements ; un débit nul signifie illimité
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate int64) *RateLimiter {
+	l := &RateLimiter{last: time.Now()}
+	l.SetRate(rate)
+	return l
+}
+
+// SetRate modifie le débit ; les lectures en attente en tiennent compte aussitôt
+func (l *RateLimiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rate < 0 {
+		rate = 0
+	}
+	l.refill(time.Now())
+	l.rate = rate
+	// Le seau contient au plus une seconde de débit
+	if l.tokens > float64(rate) {
+		l.tokens = float64(rate)
+	}
+}
+
+func (l *RateLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.rate
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+		if l.tokens > float64(l.rate) {
+			l.tokens = float64(l.rate)
+		}
+	}
+	l.last = now
+}
+
+// reserve consomme n jetons s'ils sont disponibles, sinon retourne l'attente
+// estimée ; une demande plus grande que le seau est servie dès qu'il est plein
+func (l *RateLimiter) reserve(n int) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate == 0 {
+		return 0, true
+	}
+
+	l.refill(time.Now())
+	need := float64(n)
+	if need > float64(l.rate) {
+		need = float64(l.rate)
+	}
+	if l.tokens >= need {
+		l.tokens -= float64(n)
+		return 0, true
+	}
+
+	return time.Duration((need - l.tokens) / float64(l.rate) * float64(time.Second)), false
+}
+
+// WaitN bloque jusqu'à ce que n octets puissent passer ou que ctx soit annulé
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	for {
+		delay, ok := l.reserve(n)
+		if ok {
+			return nil
+		}
+		if delay > maxLimiterWait {
+			delay = maxLimiterWait
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+// limitedReader applique un ou plusieurs limiteurs de débit à un lecteur
+type limitedReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	limiters []*RateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > limitedReadSize {
+		p = p[:limitedReadSize]
+	}
+
+	n, err := r.reader.Read(p)
+	for _, limiter := range r.limiters {
+		if waitErr := limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// SetGlobalLimit modifie le débit maximal cumulé de tous les téléchargements
+func (d *Downloader) SetGlobalLimit(bytesPerSecond int64) {
+	d.limiter.SetRate(bytesPerSecond)
+}
+
+// GlobalLimit retourne le débit maximal cumulé, 0 si illimité
+func (d *Downloader) GlobalLimit() int64 {
+	return d.limiter.Rate()
+}
+
+// SetDownloadLimit modifie le débit maximal d'un téléchargement en cours ; il
+// retourne false si le téléchargement n'est pas actif, la limite s'appliquera
+// alors à sa reprise si elle a été enregistrée
+func (d *Downloader) SetDownloadLimit(id int64, bytesPerSecond int64) bool {
+	value, ok := d.jobs.Load(id)
+	if !ok {
+		return false
+	}
+
+	value.(*job).limiter.SetRate(bytesPerSecond)
+	return true
+}
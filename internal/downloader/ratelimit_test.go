@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// Le débit obtenu à travers un ou plusieurs limiteurs est celui du plus lent
+func TestRateLimiterThroughput(t *testing.T) {
+	const size = 100000
+	tests := []struct {
+		name  string
+		rates []int64
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"illimité", []int64{0}, 0, 100 * time.Millisecond},
+		{"un limiteur", []int64{200000}, 450 * time.Millisecond, 1500 * time.Millisecond},
+		{"limiteurs global et par téléchargement", []int64{0, 200000, 1000000}, 450 * time.Millisecond, 1500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		var limiters []*RateLimiter
+		for _, rate := range tt.rates {
+			limiters = append(limiters, NewRateLimiter(rate))
+		}
+		reader := &limitedReader{ctx: context.Background(), reader: bytes.NewReader(make([]byte, size)), limiters: limiters}
+
+		start := time.Now()
+		n, err := io.Copy(io.Discard, reader)
+		elapsed := time.Since(start)
+		if err != nil || n != size {
+			t.Fatalf("%s : %d octets lus, erreur %v", tt.name, n, err)
+		}
+		if elapsed < tt.min || elapsed > tt.max {
+			t.Errorf("%s : %d octets lus en %v, attendu entre %v et %v", tt.name, size, elapsed, tt.min, tt.max)
+		}
+	}
+}
+
+// Un nouveau débit s'applique aux lectures déjà en attente
+func TestRateLimiterSetRate(t *testing.T) {
+	l := NewRateLimiter(1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l.SetRate(0)
+	}()
+
+	start := time.Now()
+	if err := l.WaitN(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("attente de %v après la levée de la limite", elapsed)
+	}
+
+	// L'annulation interrompt l'attente avec sa cause
+	l.SetRate(1)
+	cause := errors.New("pause")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(cause)
+	if err := l.WaitN(ctx, 1000); !errors.Is(err, cause) {
+		t.Errorf("WaitN : %v, attendu la cause de l'annulation", err)
+	}
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Intervalle minimal entre deux sauvegardes de l'avancement
+const stateSaveInterval = time.Second
+
+// ResumeState décrit l'avancement persisté d'un téléchargement partiel
+type ResumeState struct {
+	URL          string
+	FileName     string
+	FilePath     string
+	Size         int64
+	ETag         string
+	LastModified string
+	Retries      int
+	SpeedLimit   int64
+	Checksum     string
+	Priority     Priority
+	Options      HTTPOptions `json:"-"` // Peut contenir des cookies de session : jamais écrit à côté du fichier
+	Credentials  Credentials `json:"-"`
+	Chunks       []ChunkInfo
+}
+
+// canResume indique si le fichier partiel peut être complété : le fichier
+// temporaire doit exister et le fichier distant ne doit pas avoir changé depuis
+func (s *ResumeState) canResume(resp *http.Response, filePath string) bool {
+	if s == nil || len(s.Chunks) == 0 || s.Size != resp.ContentLength {
+		return false
+	}
+	if _, err := os.Stat(partPath(filePath)); err != nil {
+		return false
+	}
+
+	if s.ETag != "" {
+		return s.ETag == resp.Header.Get("ETag")
+	}
+	if s.LastModified != "" {
+		return s.LastModified == resp.Header.Get("Last-Modified")
+	}
+	// Sans validateur, impossible de garantir que les octets déjà écrits sont encore valides
+	return false
+}
+
+// ifRange retourne la valeur de l'en-tête If-Range : l'ETag s'il est fort,
+// sinon la date de dernière modification
+func ifRange(etag, lastModified string) string {
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return lastModified
+}
+
+// saveState transmet l'avancement courant au Store et, tant que le fichier
+// temporaire existe, l'écrit à côté de lui
+func (d *Downloader) saveState(j *job, progress *downloadProgress) {
+	state := progress.state()
+	state.URL = j.URL
+	state.Retries = int(j.retries.Load())
+	state.SpeedLimit = j.limiter.Rate()
+	state.Checksum = j.Checksum
+
+	if !progress.isFinalized() {
+		if err := writeSidecar(state); err != nil {
+			log.Printf("Erreur lors de l'écriture de l'état de reprise du téléchargement %d : %v", j.ID, err)
+		}
+	}
+
+	if d.Store == nil {
+		return
+	}
+	if err := d.Store.SaveDownloadState(j.ID, state); err != nil {
+		log.Printf("Erreur lors de l'enregistrement de l'avancement du téléchargement %d : %v", j.ID, err)
+	}
+}
@@ -0,0 +1,158 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy décrit comment réessayer une opération après une erreur transitoire
+type RetryPolicy struct {
+	MaxAttempts int           // Nombre total de tentatives, la première comprise
+	BaseDelay   time.Duration // Délai avant la deuxième tentative, doublé ensuite
+	MaxDelay    time.Duration // Plafond du délai entre deux tentatives
+	Jitter      float64       // Variation aléatoire du délai, entre 0 et 1
+}
+
+// DefaultRetryPolicy retourne la politique utilisée tant qu'aucun paramètre n'est enregistré
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// Attente maximale accordée à Retry-After : au-delà, la tentative a lieu quand
+// même, pour qu'un serveur ne bloque pas un chunk indéfiniment
+const maxRetryAfter = 10 * time.Minute
+
+// SetRetryPolicy modifie la politique de reprise ; elle s'applique aux
+// téléchargements démarrés ensuite
+func (d *Downloader) SetRetryPolicy(policy RetryPolicy) {
+	d.retryPolicy.Store(&policy)
+}
+
+// RetryPolicy retourne la politique de reprise en vigueur
+func (d *Downloader) RetryPolicy() RetryPolicy {
+	return *d.retryPolicy.Load()
+}
+
+// backoff retourne le délai à attendre après l'échec de la tentative numéro attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		delta := (rand.Float64()*2 - 1) * p.Jitter * float64(delay)
+		delay += time.Duration(delta)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// retryableError marque une erreur transitoire ; retryAfter est le délai
+// éventuellement imposé par le serveur, progressed indique que la tentative a
+// écrit des données avant d'échouer
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+	progressed bool
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+func retryable(err error) error {
+	return &retryableError{err: err}
+}
+
+// responseError convertit une réponse inattendue en erreur, transitoire pour les
+// codes 408, 429 et 5xx
+func responseError(resp *http.Response) error {
+	err := fmt.Errorf("mauvaise réponse du serveur : %s", resp.Status)
+
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	default:
+		return err
+	}
+}
+
+// parseRetryAfter interprète Retry-After, exprimé en secondes ou en date HTTP
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// withRetry exécute op jusqu'à ce qu'elle réussisse, échoue sur une erreur non
+// transitoire ou épuise les tentatives ; une tentative qui a fait avancer le
+// téléchargement remet le compte à zéro. L'attente entre deux tentatives
+// s'interrompt avec ctx.
+func (d *Downloader) withRetry(ctx context.Context, j *job, op func() error) error {
+	policy := j.retry
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		var transient *retryableError
+		if !errors.As(err, &transient) {
+			return err
+		}
+		if transient.progressed {
+			// Seuls les échecs consécutifs sans progrès sont comptés
+			attempt = 1
+		}
+		if attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		if transient.retryAfter > 0 {
+			delay = min(transient.retryAfter, maxRetryAfter)
+		}
+		j.retries.Add(1)
+		log.Printf("Téléchargement %d : nouvelle tentative dans %s après l'erreur : %v", j.ID, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0}, // Date passée
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, attendu %v", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%q) = %v, attendu environ une heure", future, got)
+	}
+}
+
+// Un Retry-After plus long que le délai maximal de la politique est respecté,
+// et la tentative est comptée
+func TestWithRetryHonoursRetryAfter(t *testing.T) {
+	d := NewDownloader(1)
+	t.Cleanup(d.Shutdown)
+	j := &job{retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}}
+
+	cause := errors.New("service indisponible")
+	calls := 0
+	start := time.Now()
+	err := d.withRetry(context.Background(), j, func() error {
+		calls++
+		return &retryableError{err: cause, retryAfter: 50 * time.Millisecond}
+	})
+	if !errors.Is(err, cause) || calls != 2 {
+		t.Fatalf("withRetry : %d appels, erreur %v ; attendu 2 appels", calls, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("nouvelle tentative après %v, avant le délai Retry-After", elapsed)
+	}
+}
+
+// Une tentative qui a écrit des données remet le compte des tentatives à zéro
+func TestWithRetryResetsAfterProgress(t *testing.T) {
+	d := NewDownloader(1)
+	t.Cleanup(d.Shutdown)
+	j := &job{retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}}
+
+	calls := 0
+	err := d.withRetry(context.Background(), j, func() error {
+		calls++
+		if calls <= 5 {
+			return &retryableError{err: errors.New("connexion réinitialisée"), progressed: true}
+		}
+		return nil
+	})
+	if err != nil || calls != 6 {
+		t.Fatalf("withRetry : %d appels, erreur %v", calls, err)
+	}
+
+	// Sans progrès, les échecs consécutifs épuisent les tentatives
+	calls = 0
+	err = d.withRetry(context.Background(), j, func() error {
+		calls++
+		return retryable(errors.New("connexion refusée"))
+	})
+	if err == nil || calls != 2 {
+		t.Fatalf("withRetry : %d appels, erreur %v ; attendu 2 appels", calls, err)
+	}
+}
@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status est l'état enregistré d'un téléchargement
+type Status string
+
+const (
+	StatusPending     Status = "pending"     // En attente d'une place dans la file
+	StatusDownloading Status = "downloading" // Transfert en cours, ou interrompu par l'arrêt du programme
+	StatusPaused      Status = "paused"
+	StatusCompleted   Status = "completed"
+	StatusFailed      Status = "failed"
+	StatusCorrupted   Status = "corrupted" // Téléchargé, mais l'empreinte ne correspond pas
+	StatusCancelled   Status = "cancelled"
+	StatusDeleted     Status = "deleted" // Retiré de la liste ; aucun changement ne suit
+)
+
+// ErrInvalidTransition signale un changement de statut interdit par la table des transitions
+var ErrInvalidTransition = errors.New("changement de statut interdit")
+
+// transitions liste, pour chaque statut, ceux qui peuvent lui succéder
+var transitions = map[Status][]Status{
+	StatusPending:     {StatusDownloading, StatusPaused, StatusFailed, StatusCancelled, StatusDeleted},
+	StatusDownloading: {StatusPaused, StatusCompleted, StatusFailed, StatusCorrupted, StatusCancelled, StatusDeleted},
+	StatusPaused:      {StatusPending, StatusDownloading, StatusCancelled, StatusDeleted},
+	StatusFailed:      {StatusPending, StatusDownloading, StatusCancelled, StatusDeleted},
+	StatusCorrupted:   {StatusPending, StatusDownloading, StatusCancelled, StatusDeleted},
+	StatusCancelled:   {StatusDeleted},
+	StatusCompleted:   {StatusDeleted},
+	StatusDeleted:     nil,
+}
+
+// CheckTransition retourne ErrInvalidTransition si un téléchargement ne peut pas
+// passer du statut from au statut to ; rester dans le même statut est permis
+func CheckTransition(from, to Status) error {
+	if _, known := transitions[to]; !known {
+		return fmt.Errorf("statut inconnu : %q", to)
+	}
+	if from == to {
+		return nil
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w : de %q à %q", ErrInvalidTransition, from, to)
+}
+
+// Status retourne le statut auquel l'événement fait passer le téléchargement,
+// false s'il n'en change pas
+func (e Event) Status() (Status, bool) {
+	switch e.Type {
+	case EventAdded, EventResumed:
+		// Un téléchargement repris attend à nouveau sa place dans la file
+		return StatusPending, true
+	case EventStarted:
+		return StatusDownloading, true
+	case EventPaused:
+		return StatusPaused, true
+	case EventCompleted:
+		return StatusCompleted, true
+	case EventFailed:
+		if errors.Is(e.Err, ErrCorrupted) {
+			return StatusCorrupted, true
+		}
+		return StatusFailed, true
+	case EventCancelled:
+		return StatusCancelled, true
+	case EventDeleted:
+		return StatusDeleted, true
+	default:
+		return "", false
+	}
+}
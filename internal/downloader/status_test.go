@@ -0,0 +1,93 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		ok       bool
+	}{
+		{StatusPending, StatusDownloading, true},
+		{StatusPending, StatusCompleted, false},
+		{StatusDownloading, StatusPaused, true},
+		{StatusDownloading, StatusCorrupted, true},
+		{StatusDownloading, StatusPending, false},
+		{StatusPaused, StatusPending, true},
+		{StatusPaused, StatusCompleted, false},
+		{StatusPaused, StatusPaused, true}, // Rester dans le même statut est permis
+		{StatusFailed, StatusPending, true},
+		{StatusFailed, StatusPaused, false},
+		{StatusCorrupted, StatusDownloading, true},
+		{StatusCancelled, StatusPending, false},
+		{StatusCancelled, StatusDeleted, true},
+		{StatusCompleted, StatusDownloading, false},
+		{StatusCompleted, StatusDeleted, true},
+		{StatusDeleted, StatusPending, false},
+		{"", StatusPending, false},
+	}
+	for _, tt := range tests {
+		err := CheckTransition(tt.from, tt.to)
+		if (err == nil) != tt.ok || (err != nil && !errors.Is(err, ErrInvalidTransition)) {
+			t.Errorf("CheckTransition(%q, %q) = %v, permis attendu : %v", tt.from, tt.to, err, tt.ok)
+		}
+	}
+
+	// Un statut cible inconnu est refusé, même depuis lui-même
+	if err := CheckTransition("archived", "archived"); err == nil || errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("statut inconnu : %v", err)
+	}
+}
+
+// Tout statut peut mener à la suppression, après laquelle plus rien ne change
+func TestTransitionsToDeleted(t *testing.T) {
+	for from := range transitions {
+		if err := CheckTransition(from, StatusDeleted); err != nil {
+			t.Errorf("%q ne peut pas être supprimé : %v", from, err)
+		}
+		if from != StatusDeleted && CheckTransition(StatusDeleted, from) == nil {
+			t.Errorf("un téléchargement supprimé peut repasser à %q", from)
+		}
+	}
+}
+
+func TestEventStatus(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  Status
+		ok    bool
+	}{
+		{Event{Type: EventAdded}, StatusPending, true},
+		{Event{Type: EventResumed}, StatusPending, true},
+		{Event{Type: EventStarted}, StatusDownloading, true},
+		{Event{Type: EventProgress}, "", false},
+		{Event{Type: EventPaused}, StatusPaused, true},
+		{Event{Type: EventCompleted}, StatusCompleted, true},
+		{Event{Type: EventFailed, Err: errors.New("refusé")}, StatusFailed, true},
+		{Event{Type: EventFailed, Err: fmt.Errorf("vérification : %w", ErrCorrupted)}, StatusCorrupted, true},
+		{Event{Type: EventCancelled}, StatusCancelled, true},
+		{Event{Type: EventDeleted}, StatusDeleted, true},
+		{Event{Type: EventQueueChanged}, "", false},
+		{Event{Type: EventLowDiskSpace}, "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := tt.event.Status(); got != tt.want || ok != tt.ok {
+			t.Errorf("%v : Status() = %q, %v ; attendu %q, %v", tt.event, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// Le statut retenu d'un téléchargement supprimé est oublié
+func TestDeleteForgetsStatus(t *testing.T) {
+	d := newTestDownloader(t)
+	d.emit(Event{Type: EventPaused, ID: 1})
+	if err := d.DeleteDownload(1, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.statuses.Load(int64(1)); ok {
+		t.Error("statut toujours retenu après la suppression")
+	}
+}
@@ -0,0 +1,58 @@
+package downloader
+
+import "fmt"
+
+// Store enregistre les téléchargements. À la différence des événements, le
+// Downloader attend sa réponse : l'ID d'un nouveau téléchargement, l'état à
+// partir duquel en reprendre un. Sans Store, les IDs sont attribués en mémoire
+// et les téléchargements ne peuvent pas être repris.
+type Store interface {
+	AddDownload(url string, totalSize int64) (int64, error)
+	SaveDownloadState(id int64, state ResumeState) error
+	GetDownloadState(id int64) (*ResumeState, error)
+	GetDownloadStatus(id int64) (Status, error) // Vide si le téléchargement est inconnu
+}
+
+// addToStore enregistre un nouveau téléchargement et retourne son ID
+func (d *Downloader) addToStore(url string) (int64, error) {
+	if d.Store == nil {
+		return d.nextID.Add(1), nil
+	}
+	return d.Store.AddDownload(url, 0)
+}
+
+// loadState retourne l'état enregistré du téléchargement, nil s'il n'y en a pas
+func (d *Downloader) loadState(id int64) (*ResumeState, error) {
+	if d.Store == nil {
+		return nil, nil
+	}
+	return d.Store.GetDownloadState(id)
+}
+
+// status retourne le statut du téléchargement : celui de son dernier événement
+// publié, sinon celui enregistré par le Store ; vide s'il est inconnu
+func (d *Downloader) status(id int64) (Status, error) {
+	if status, ok := d.statuses.Load(id); ok {
+		return status.(Status), nil
+	}
+	if d.Store == nil {
+		return "", nil
+	}
+	return d.Store.GetDownloadStatus(id)
+}
+
+// checkTransition retourne ErrInvalidTransition si le téléchargement ne peut pas
+// passer au statut to ; un téléchargement au statut inconnu n'est pas vérifié
+func (d *Downloader) checkTransition(id int64, to Status) error {
+	from, err := d.status(id)
+	if err != nil {
+		return fmt.Errorf("impossible de lire le statut du téléchargement %d : %v", id, err)
+	}
+	if from == "" {
+		return nil
+	}
+	if err := CheckTransition(from, to); err != nil {
+		return fmt.Errorf("téléchargement %d : %w", id, err)
+	}
+	return nil
+}
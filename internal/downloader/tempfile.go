@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Suffixes des fichiers temporaires écrits à côté du fichier final : les données
+// et, en JSON, l'avancement nécessaire à la reprise
+const (
+	partSuffix        = ".part"
+	stateSuffix       = ".part.json"
+	sidecarTempSuffix = stateSuffix + ".tmp" // État en cours d'écriture
+)
+
+// partPath retourne le chemin du fichier où les données sont écrites tant que
+// le téléchargement n'est pas terminé et vérifié
+func partPath(filePath string) string {
+	return filePath + partSuffix
+}
+
+func sidecarPath(filePath string) string {
+	return filePath + stateSuffix
+}
+
+// createPart crée le fichier temporaire de filePath ; O_EXCL réserve le nom
+// tant que le téléchargement est en cours. os.ErrExist est retourné si le
+// fichier final ou le fichier temporaire existe déjà.
+func createPart(filePath string) (*os.File, error) {
+	if _, err := os.Lstat(filePath); err == nil {
+		return nil, fmt.Errorf("%s : %w", filePath, os.ErrExist)
+	}
+	return os.OpenFile(partPath(filePath), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+}
+
+// preallocateFile réserve la place du fichier pour éviter la fragmentation et
+// échouer tôt si le disque est plein ; à défaut, le fichier est étendu sans
+// allouer ses blocs (fichier creux)
+func preallocateFile(f *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	err := allocate(f, size)
+	if err == nil || isNoSpace(err) {
+		return err
+	}
+	return f.Truncate(size)
+}
+
+// finalizePart remplace atomiquement le fichier final par le fichier temporaire
+// complet et supprime l'état de reprise devenu inutile
+func finalizePart(filePath string) error {
+	if err := os.Rename(partPath(filePath), filePath); err != nil {
+		return fmt.Errorf("impossible de renommer le fichier temporaire : %v", err)
+	}
+	if err := os.Remove(sidecarPath(filePath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("impossible de supprimer l'état de reprise : %v", err)
+	}
+	return nil
+}
+
+// writeSidecar enregistre l'avancement à côté du fichier temporaire ; l'écriture
+// passe par un fichier intermédiaire pour ne jamais laisser un état tronqué
+func writeSidecar(state ResumeState) error {
+	// Le fichier est lisible par tous : jamais d'identifiants dans l'URL
+	state.URL, _ = splitUserinfo(state.URL)
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := sidecarPath(state.FilePath)
+	tmp := state.FilePath + sidecarTempSuffix
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readSidecar relit l'avancement enregistré à côté du fichier temporaire, ou
+// retourne nil s'il n'y en a pas
+func readSidecar(filePath string) (*ResumeState, error) {
+	data, err := os.ReadFile(sidecarPath(filePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state ResumeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// RemovePartial supprime le fichier temporaire et l'état de reprise de filePath,
+// après une annulation ou la suppression d'un téléchargement
+func RemovePartial(filePath string) error {
+	for _, path := range []string{partPath(filePath), sidecarPath(filePath), filePath + sidecarTempSuffix} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
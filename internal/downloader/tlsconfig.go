@@ -0,0 +1,279 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Préfixe des empreintes de clé publique, au format de curl --pinnedpubkey
+const pinPrefix = "sha256//"
+
+// ErrCertificatePin signale un certificat dont la clé publique ne correspond à
+// aucune empreinte épinglée pour l'hôte
+var ErrCertificatePin = errors.New("clé publique du certificat non épinglée")
+
+// ClientCertificate présente un certificat client aux hôtes correspondant au motif
+type ClientCertificate struct {
+	Pattern  string
+	CertFile string
+	KeyFile  string
+}
+
+// CertificatePin restreint les certificats acceptés pour les hôtes correspondant
+// au motif : l'un d'eux doit avoir une clé publique dont l'empreinte SHA-256 du
+// SubjectPublicKeyInfo, en base64, est SPKI
+type CertificatePin struct {
+	Pattern string
+	SPKI    string
+}
+
+// TLSSettings complète la vérification TLS des certificats serveur
+type TLSSettings struct {
+	CAFiles       []string // Autorités ajoutées à celles du système, au format PEM
+	ClientCerts   []ClientCertificate
+	Pins          []CertificatePin
+	InsecureHosts []string // Motifs des hôtes dont le certificat n'est pas vérifié
+}
+
+// ParseTLSSettings lit les listes saisies dans les paramètres, une entrée par
+// ligne : fichiers d'autorités, "motif certificat clé", "motif sha256//empreinte"
+// et motifs des hôtes non vérifiés ; les lignes vides et celles commençant par #
+// sont ignorées
+func ParseTLSSettings(caFiles, clientCerts, pins, insecureHosts string) (TLSSettings, error) {
+	var settings TLSSettings
+
+	err := scanLines(caFiles, func(line int, fields []string) error {
+		settings.CAFiles = append(settings.CAFiles, strings.Join(fields, " "))
+		return nil
+	})
+	if err != nil {
+		return TLSSettings{}, err
+	}
+
+	err = scanLines(clientCerts, func(line int, fields []string) error {
+		if len(fields) != 3 {
+			return fmt.Errorf("certificats clients, ligne %d : format attendu « motif certificat clé »", line)
+		}
+		pattern, err := parsePattern(fields[0])
+		if err != nil {
+			return fmt.Errorf("certificats clients, ligne %d : %v", line, err)
+		}
+		settings.ClientCerts = append(settings.ClientCerts, ClientCertificate{Pattern: pattern, CertFile: fields[1], KeyFile: fields[2]})
+		return nil
+	})
+	if err != nil {
+		return TLSSettings{}, err
+	}
+
+	err = scanLines(pins, func(line int, fields []string) error {
+		if len(fields) != 2 {
+			return fmt.Errorf("épinglage, ligne %d : format attendu « motif sha256//empreinte »", line)
+		}
+		pattern, err := parsePattern(fields[0])
+		if err != nil {
+			return fmt.Errorf("épinglage, ligne %d : %v", line, err)
+		}
+		spki := strings.TrimPrefix(fields[1], pinPrefix)
+		if sum, err := base64.StdEncoding.DecodeString(spki); err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("épinglage, ligne %d : empreinte SHA-256 en base64 attendue : %s", line, fields[1])
+		}
+		settings.Pins = append(settings.Pins, CertificatePin{Pattern: pattern, SPKI: spki})
+		return nil
+	})
+	if err != nil {
+		return TLSSettings{}, err
+	}
+
+	err = scanLines(insecureHosts, func(line int, fields []string) error {
+		if len(fields) != 1 {
+			return fmt.Errorf("hôtes non vérifiés, ligne %d : un motif par ligne", line)
+		}
+		pattern, err := parsePattern(fields[0])
+		if err != nil {
+			return fmt.Errorf("hôtes non vérifiés, ligne %d : %v", line, err)
+		}
+		settings.InsecureHosts = append(settings.InsecureHosts, pattern)
+		return nil
+	})
+	if err != nil {
+		return TLSSettings{}, err
+	}
+
+	return settings, nil
+}
+
+// SPKIHash retourne l'empreinte à épingler pour la clé publique d'un certificat
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// clientCertificate est un certificat client chargé
+type clientCertificate struct {
+	pattern string
+	cert    tls.Certificate
+}
+
+// tlsPolicy est la forme chargée de TLSSettings
+type tlsPolicy struct {
+	roots    *x509.CertPool // nil pour les seules autorités du système
+	certs    []clientCertificate
+	pins     []CertificatePin
+	insecure []string
+}
+
+// loadTLSPolicy lit les fichiers d'autorités et de certificats clients
+func loadTLSPolicy(settings TLSSettings) (*tlsPolicy, error) {
+	policy := &tlsPolicy{pins: settings.Pins, insecure: settings.InsecureHosts}
+
+	if len(settings.CAFiles) > 0 {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		for _, file := range settings.CAFiles {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("impossible de lire l'autorité %s : %v", file, err)
+			}
+			if !roots.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("aucun certificat PEM dans %s", file)
+			}
+		}
+		policy.roots = roots
+	}
+
+	for _, entry := range settings.ClientCerts {
+		cert, err := tls.LoadX509KeyPair(entry.CertFile, entry.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("impossible de charger le certificat client %s : %v", entry.CertFile, err)
+		}
+		policy.certs = append(policy.certs, clientCertificate{pattern: entry.Pattern, cert: cert})
+	}
+
+	return policy, nil
+}
+
+// clientCertificate retourne la première règle de certificat client de host
+func (p *tlsPolicy) clientCertificate(host string) *clientCertificate {
+	for i := range p.certs {
+		if matched, _ := path.Match(p.certs[i].pattern, host); matched {
+			return &p.certs[i]
+		}
+	}
+	return nil
+}
+
+func (p *tlsPolicy) isInsecure(host string) bool {
+	for _, pattern := range p.insecure {
+		if matched, _ := path.Match(pattern, host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// config retourne la configuration TLS des connexions vers host. La vérification,
+// faite par verifyConnection, dépend du nom du serveur contacté : cible ou proxy HTTPS.
+func (p *tlsPolicy) config(host string) *tls.Config {
+	config := &tls.Config{
+		// Vérification faite par VerifyConnection, hôte par hôte
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			// Le nom n'est pas transmis (SNI) pour une adresse IP
+			if state.ServerName == "" {
+				state.ServerName = host
+			}
+			return p.verifyConnection(state)
+		},
+	}
+	if entry := p.clientCertificate(host); entry != nil {
+		config.Certificates = []tls.Certificate{entry.cert}
+	}
+	return config
+}
+
+// verifyConnection vérifie la chaîne du serveur comme le ferait crypto/tls, sauf
+// pour les hôtes non vérifiés, puis les empreintes épinglées pour l'hôte
+func (p *tlsPolicy) verifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("aucun certificat présenté par le serveur")
+	}
+	host := strings.ToLower(state.ServerName)
+
+	chain := state.PeerCertificates
+	if !p.isInsecure(host) {
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		chains, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         p.roots,
+			Intermediates: intermediates,
+			DNSName:       state.ServerName,
+		})
+		if err != nil {
+			return err
+		}
+		chain = chains[0]
+	}
+
+	var pinned bool
+	for _, pin := range p.pins {
+		matched, _ := path.Match(pin.Pattern, host)
+		if !matched {
+			continue
+		}
+		pinned = true
+		for _, cert := range chain {
+			if SPKIHash(cert) == pin.SPKI {
+				return nil
+			}
+		}
+	}
+	if pinned {
+		return fmt.Errorf("%w : %s", ErrCertificatePin, host)
+	}
+	return nil
+}
+
+// tlsSettings conserve la politique TLS en vigueur
+type tlsSettings struct {
+	mu     sync.RWMutex
+	policy *tlsPolicy
+}
+
+func (s *tlsSettings) set(policy *tlsPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policy = policy
+}
+
+func (s *tlsSettings) get() *tlsPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.policy
+}
+
+// SetTLSSettings charge les autorités et certificats clients, puis remplace la
+// configuration TLS des connexions suivantes ; en cas d'erreur, la configuration
+// précédente est conservée
+func (d *Downloader) SetTLSSettings(settings TLSSettings) error {
+	policy, err := loadTLSPolicy(settings)
+	if err != nil {
+		return err
+	}
+	d.tls.set(policy)
+	d.transport.closeIdleConnections()
+	return nil
+}
@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestVerifyConnection(t *testing.T) {
+	server := startFileServer(t, true)
+	cert := server.Certificate() // Valable pour example.com et 127.0.0.1
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	otherSum := sha256.Sum256([]byte("autre clé"))
+	otherPin := base64.StdEncoding.EncodeToString(otherSum[:])
+
+	tests := []struct {
+		name   string
+		policy tlsPolicy
+		host   string
+		err    bool
+		pinErr bool // L'erreur est ErrCertificatePin
+	}{
+		{name: "autorité connue", policy: tlsPolicy{roots: roots}, host: "example.com"},
+		{name: "adresse IP", policy: tlsPolicy{roots: roots}, host: "127.0.0.1"},
+		{name: "autorité inconnue", policy: tlsPolicy{}, host: "example.com", err: true},
+		{name: "nom différent", policy: tlsPolicy{roots: roots}, host: "example.org", err: true},
+		{name: "hôte non vérifié", policy: tlsPolicy{insecure: []string{"*.com"}}, host: "example.com"},
+		{name: "autre hôte non vérifié", policy: tlsPolicy{insecure: []string{"*.org"}}, host: "example.com", err: true},
+		{
+			name:   "empreinte correspondante",
+			policy: tlsPolicy{roots: roots, pins: []CertificatePin{{"example.com", SPKIHash(cert)}}},
+			host:   "example.com",
+		},
+		{
+			name:   "empreinte différente",
+			policy: tlsPolicy{roots: roots, pins: []CertificatePin{{"example.com", otherPin}}},
+			host:   "example.com",
+			err:    true,
+			pinErr: true,
+		},
+		{
+			name:   "l'une des empreintes correspond",
+			policy: tlsPolicy{roots: roots, pins: []CertificatePin{{"*.com", otherPin}, {"example.*", SPKIHash(cert)}}},
+			host:   "example.com",
+		},
+		{
+			name:   "empreinte d'un autre hôte",
+			policy: tlsPolicy{roots: roots, pins: []CertificatePin{{"example.org", otherPin}}},
+			host:   "example.com",
+		},
+		{
+			// L'épinglage s'applique aussi aux hôtes dont la chaîne n'est pas vérifiée
+			name:   "hôte non vérifié et empreinte différente",
+			policy: tlsPolicy{insecure: []string{"example.com"}, pins: []CertificatePin{{"example.com", otherPin}}},
+			host:   "example.com",
+			err:    true,
+			pinErr: true,
+		},
+	}
+	for _, tt := range tests {
+		err := tt.policy.verifyConnection(tls.ConnectionState{ServerName: tt.host, PeerCertificates: []*x509.Certificate{cert}})
+		if (err != nil) != tt.err || errors.Is(err, ErrCertificatePin) != tt.pinErr {
+			t.Errorf("%s : %v", tt.name, err)
+		}
+	}
+
+	if err := (&tlsPolicy{}).verifyConnection(tls.ConnectionState{ServerName: "example.com"}); err == nil {
+		t.Error("connexion sans certificat acceptée")
+	}
+}
+
+// Une empreinte épinglée qui ne correspond pas fait échouer le téléchargement
+func TestDownloadPinnedCertificate(t *testing.T) {
+	server := startFileServer(t, true)
+	otherSum := sha256.Sum256([]byte("autre clé"))
+
+	tests := []struct {
+		name string
+		pin  string
+		err  bool
+	}{
+		{"empreinte correspondante", SPKIHash(server.Certificate()), false},
+		{"empreinte différente", base64.StdEncoding.EncodeToString(otherSum[:]), true},
+	}
+	for _, tt := range tests {
+		d := newTestDownloader(t)
+		settings := TLSSettings{
+			InsecureHosts: []string{"127.0.0.1"},
+			Pins:          []CertificatePin{{Pattern: "127.0.0.1", SPKI: tt.pin}},
+		}
+		if err := d.SetTLSSettings(settings); err != nil {
+			t.Fatal(err)
+		}
+		_, err := fetch(d, server.URL+"/pinned.bin")
+		if (err != nil) != tt.err || (tt.err && !errors.Is(err, ErrCertificatePin)) {
+			t.Errorf("%s : %v", tt.name, err)
+		}
+	}
+}
@@ -2,12 +2,20 @@ package ui
 
 import (
 	"gestionnaire-telechargement/internal/downloader"
+	"sync"
 
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
+// ChunkProgressBar affiche chaque chunk à sa position dans le fichier, avec la
+// partie déjà téléchargée ; les découpages en cours de téléchargement y
+// apparaissent comme de nouveaux segments
 type ChunkProgressBar struct {
-	widget.ProgressBar
+	widget.BaseWidget
+	mu     sync.Mutex // UpdateChunks est appelé depuis la goroutine des événements
 	chunks []downloader.ChunkInfo
 }
 
@@ -20,6 +28,124 @@ func NewChunkProgressBar(chunks []downloader.ChunkInfo) *ChunkProgressBar {
 }
 
 func (c *ChunkProgressBar) UpdateChunks(chunks []downloader.ChunkInfo) {
+	c.mu.Lock()
 	c.chunks = chunks
+	c.mu.Unlock()
 	c.Refresh()
 }
+
+// snapshot retourne une copie des chunks affichés
+func (c *ChunkProgressBar) snapshot() []downloader.ChunkInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]downloader.ChunkInfo(nil), c.chunks...)
+}
+
+func (c *ChunkProgressBar) CreateRenderer() fyne.WidgetRenderer {
+	r := &chunkProgressRenderer{
+		bar:        c,
+		background: canvas.NewRectangle(theme.Color(theme.ColorNameInputBackground)),
+	}
+	r.Refresh()
+	return r
+}
+
+// chunkProgressRenderer dessine la copie des chunks prise à son dernier Refresh,
+// pour que Layout ne voie jamais plus de chunks que de rectangles
+type chunkProgressRenderer struct {
+	bar        *ChunkProgressBar
+	background *canvas.Rectangle
+
+	mu         sync.Mutex
+	chunks     []downloader.ChunkInfo
+	fills      []*canvas.Rectangle
+	separators []*canvas.Rectangle
+	objects    []fyne.CanvasObject
+}
+
+// chunkBounds retourne le début et la fin de chaque chunk ; les chunks
+// enregistrés sans offsets se suivent dans l'ordre de leurs IDs
+func chunkBounds(chunks []downloader.ChunkInfo) (starts, ends []int64, total int64) {
+	starts = make([]int64, len(chunks))
+	ends = make([]int64, len(chunks))
+
+	var offset int64
+	for i, chunk := range chunks {
+		starts[i], ends[i] = chunk.Start, chunk.End
+		if chunk.End == 0 {
+			starts[i], ends[i] = offset, offset+chunk.Size
+		}
+		offset = ends[i]
+		total = max(total, ends[i])
+	}
+	return starts, ends, total
+}
+
+func (r *chunkProgressRenderer) Layout(size fyne.Size) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.background.Resize(size)
+	r.background.Move(fyne.NewPos(0, 0))
+
+	starts, ends, total := chunkBounds(r.chunks)
+	if total <= 0 {
+		return
+	}
+
+	scale := size.Width / float32(total)
+	for i, chunk := range r.chunks {
+		x := float32(starts[i]) * scale
+		width := float32(min(chunk.Downloaded, ends[i]-starts[i])) * scale
+
+		r.fills[i].Move(fyne.NewPos(x, 0))
+		r.fills[i].Resize(fyne.NewSize(width, size.Height))
+
+		// Séparateur au début de chaque chunk, sauf le premier
+		r.separators[i].Move(fyne.NewPos(x, 0))
+		r.separators[i].Resize(fyne.NewSize(1, size.Height))
+		r.separators[i].Hidden = starts[i] == 0
+	}
+}
+
+func (r *chunkProgressRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(100, theme.Padding()*4)
+}
+
+func (r *chunkProgressRenderer) Refresh() {
+	r.mu.Lock()
+	r.background.FillColor = theme.Color(theme.ColorNameInputBackground)
+
+	r.chunks = r.bar.snapshot()
+	count := len(r.chunks)
+	for len(r.fills) < count {
+		r.fills = append(r.fills, canvas.NewRectangle(nil))
+		r.separators = append(r.separators, canvas.NewRectangle(nil))
+	}
+	r.fills = r.fills[:count]
+	r.separators = r.separators[:count]
+
+	r.objects = []fyne.CanvasObject{r.background}
+	for i := range r.fills {
+		r.fills[i].FillColor = theme.Color(theme.ColorNamePrimary)
+		r.separators[i].FillColor = theme.Color(theme.ColorNameForeground)
+		r.objects = append(r.objects, r.fills[i])
+	}
+	for _, separator := range r.separators {
+		r.objects = append(r.objects, separator)
+	}
+	r.mu.Unlock()
+
+	r.Layout(r.bar.Size())
+	canvas.Refresh(r.bar)
+}
+
+func (r *chunkProgressRenderer) Objects() []fyne.CanvasObject {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.objects
+}
+
+func (r *chunkProgressRenderer) Destroy() {}
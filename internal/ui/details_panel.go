@@ -2,8 +2,11 @@ package ui
 
 import (
 	"fmt"
+	"gestionnaire-telechargement/internal/database"
 	"gestionnaire-telechargement/internal/downloader"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -12,6 +15,9 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+// Nombre de changements de statut affichés, les plus récents
+const maxHistoryEntries = 10
+
 type DetailsPanel struct {
 	ui               *UI
 	card             *widget.Card
@@ -21,9 +27,14 @@ type DetailsPanel struct {
 	sizeLabel        *widget.Label
 	downloadedLabel  *widget.Label
 	statusLabel      *widget.Label
+	retriesLabel     *widget.Label
+	startedLabel     *widget.Label
+	finishedLabel    *widget.Label
+	lastErrorLabel   *widget.Label
 	savePathLabel    *widget.Label       // Ajouté
 	progressBar      *widget.ProgressBar // Ajouté
 	chunkProgressBar *ChunkProgressBar   // Ajouté
+	infiniteBar      *widget.ProgressBarInfinite
 }
 
 func NewDetailsPanel(ui *UI) *DetailsPanel {
@@ -37,15 +48,21 @@ func NewDetailsPanel(ui *UI) *DetailsPanel {
 	dp.sizeLabel = widget.NewLabel("")
 	dp.downloadedLabel = widget.NewLabel("")
 	dp.statusLabel = widget.NewLabel("")
+	dp.retriesLabel = widget.NewLabel("")
+	dp.startedLabel = widget.NewLabel("")
+	dp.finishedLabel = widget.NewLabel("")
+	dp.lastErrorLabel = widget.NewLabel("")
+	dp.lastErrorLabel.Wrapping = fyne.TextWrapWord
 	dp.savePathLabel = widget.NewLabel("")
 	dp.progressBar = widget.NewProgressBar()
+	dp.infiniteBar = widget.NewProgressBarInfinite()
 	dp.chunkProgressBar = NewChunkProgressBar(nil) // Assurez-vous que cette fonction existe
 
 	return dp
 }
 
-func (dp *DetailsPanel) showDownloadDetails(url string) {
-	details, err := dp.ui.db.GetDownloadDetails(url)
+func (dp *DetailsPanel) showDownloadDetails(id int64) {
+	details, err := dp.ui.db.GetDownloadDetails(id)
 	if err != nil {
 		dialog.ShowError(err, dp.ui.window)
 		return
@@ -55,13 +72,12 @@ func (dp *DetailsPanel) showDownloadDetails(url string) {
 
 	// Mettre à jour les labels avec les détails du téléchargement
 	dp.urlLabel.SetText(details.URL)
-	dp.sizeLabel.SetText(formatSize(details.Size))
+	dp.sizeLabel.SetText(formatTotalSize(details.Size))
 	dp.statusLabel.SetText(formatStatus(details.Status))
 	dp.savePathLabel.SetText(details.SavePath)
 
 	// Mettre à jour la barre de progression globale
-	progress := float64(details.DownloadedSize) / float64(details.Size)
-	dp.progressBar.SetValue(progress)
+	dp.progressBar.SetValue(downloadedRatio(details))
 
 	// Mettre à jour la barre de progression des chunks
 	dp.chunkProgressBar.UpdateChunks(details.Chunks)
@@ -88,10 +104,10 @@ func (dp *DetailsPanel) updateDetailsContainer() {
 	header := container.NewBorder(nil, nil, nil, closeButton)
 
 	dp.container.Add(header)
-	dp.container.Add(widget.NewLabel(fmt.Sprintf("Nom du fichier: %s", getFileName(dp.selectedDownload.URL))))
+	dp.container.Add(widget.NewLabel(fmt.Sprintf("Nom du fichier: %s", getFileName(dp.selectedDownload.FileName, dp.selectedDownload.URL))))
 	dp.container.Add(widget.NewLabel(fmt.Sprintf("Chemin de sauvegarde: %s", dp.selectedDownload.SavePath)))
 
-	dp.sizeLabel = widget.NewLabel(fmt.Sprintf("Taille totale: %s", formatSize(dp.selectedDownload.Size)))
+	dp.sizeLabel = widget.NewLabel(fmt.Sprintf("Taille totale: %s", formatTotalSize(dp.selectedDownload.Size)))
 	dp.container.Add(dp.sizeLabel)
 
 	dp.downloadedLabel = widget.NewLabel(fmt.Sprintf("Téléchargé: %s", formatSize(dp.selectedDownload.DownloadedSize)))
@@ -100,8 +116,27 @@ func (dp *DetailsPanel) updateDetailsContainer() {
 	dp.statusLabel = widget.NewLabel(fmt.Sprintf("Statut: %s", formatStatus(dp.selectedDownload.Status)))
 	dp.container.Add(dp.statusLabel)
 
+	dp.retriesLabel = widget.NewLabel(fmt.Sprintf(T("retriesLabel"), dp.selectedDownload.Retries))
+	dp.container.Add(dp.retriesLabel)
+
+	dp.container.Add(widget.NewLabel(fmt.Sprintf(T("createdAtLabel"), formatTime(dp.selectedDownload.CreatedAt))))
+	dp.container.Add(dp.startedLabel)
+	dp.container.Add(dp.finishedLabel)
+	dp.container.Add(dp.lastErrorLabel)
+	dp.updateHistory(dp.selectedDownload)
+	dp.addStatusHistory(dp.selectedDownload.ID)
+
+	dp.container.Add(widget.NewLabel(T("priority")))
+	dp.container.Add(dp.createPrioritySelect(dp.selectedDownload))
+
+	dp.container.Add(widget.NewLabel(T("speedLimitDownload")))
+	dp.container.Add(dp.createSpeedLimitEditor(dp.selectedDownload))
+
 	// Remplacer la section des barres de progression individuelles par une seule barre de progression découpée en chunks
-	if len(dp.selectedDownload.Chunks) > 0 {
+	if dp.selectedDownload.Size <= 0 && dp.selectedDownload.Status == downloader.StatusDownloading {
+		// Sans taille connue, les chunks ne peuvent pas être placés dans le fichier
+		dp.container.Add(dp.infiniteBar)
+	} else if len(dp.selectedDownload.Chunks) > 0 {
 		dp.container.Add(widget.NewLabel("Progression des chunks:"))
 		dp.chunkProgressBar.UpdateChunks(dp.selectedDownload.Chunks)
 		dp.chunkProgressBar.Resize(fyne.NewSize(200, 20)) // Définir une taille
@@ -112,26 +147,109 @@ func (dp *DetailsPanel) updateDetailsContainer() {
 	dp.setVSplitOffset(0.7)
 }
 
+// createPrioritySelect modifie la priorité du téléchargement et sa place dans la file
+func (dp *DetailsPanel) createPrioritySelect(download *downloader.Download) fyne.CanvasObject {
+	prioritySelect := widget.NewSelect(priorityLabels(), nil)
+	prioritySelect.SetSelected(priorityLabel(download.Priority))
+
+	prioritySelect.OnChanged = func(label string) {
+		priority := priorityFromLabel(label)
+		if priority == download.Priority {
+			return
+		}
+
+		if err := dp.ui.db.SetDownloadPriority(download.ID, priority); err != nil {
+			dialog.ShowError(fmt.Errorf("impossible d'enregistrer la priorité : %v", err), dp.ui.window)
+			return
+		}
+		download.Priority = priority
+		if dp.ui.downloader.SetPriority(download.ID, priority) {
+			dp.ui.downloadList.applyQueueOrder(dp.ui.downloader.QueueOrder())
+		}
+	}
+
+	return prioritySelect
+}
+
+// createSpeedLimitEditor permet de modifier la limite de débit du téléchargement sans l'interrompre
+func (dp *DetailsPanel) createSpeedLimitEditor(download *downloader.Download) fyne.CanvasObject {
+	limitEntry := widget.NewEntry()
+	limitEntry.SetText(strconv.FormatInt(download.SpeedLimit/1024, 10))
+
+	applyButton := widget.NewButton(T("apply"), func() {
+		limit, err := parseSpeedLimit(limitEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, dp.ui.window)
+			return
+		}
+
+		if err := dp.ui.db.SetDownloadSpeedLimit(download.ID, limit); err != nil {
+			dialog.ShowError(fmt.Errorf("impossible d'enregistrer la limite de débit : %v", err), dp.ui.window)
+			return
+		}
+		dp.ui.downloader.SetDownloadLimit(download.ID, limit)
+		download.SpeedLimit = limit
+	})
+
+	return container.NewBorder(nil, nil, nil, applyButton, limitEntry)
+}
+
 func (dp *DetailsPanel) updateProgress() {
 	if dp.selectedDownload == nil {
 		return
 	}
 
-	details, err := dp.ui.db.GetDownloadDetails(dp.selectedDownload.URL)
+	details, err := dp.ui.db.GetDownloadDetails(dp.selectedDownload.ID)
 	if err != nil {
 		return
 	}
 
 	// Mettre à jour la barre de progression globale
-	progress := float64(details.DownloadedSize) / float64(details.Size)
-	dp.progressBar.SetValue(progress)
+	dp.progressBar.SetValue(downloadedRatio(details))
 
 	// Mettre à jour la barre de progression des chunks
 	dp.chunkProgressBar.UpdateChunks(details.Chunks)
 
+	// La taille d'un fichier sans taille annoncée n'est connue qu'à la fin
+	dp.sizeLabel.SetText(fmt.Sprintf("Taille totale: %s", formatTotalSize(details.Size)))
+	dp.downloadedLabel.SetText(fmt.Sprintf("Téléchargé: %s", formatSize(details.DownloadedSize)))
+
+	if dp.retriesLabel != nil {
+		dp.retriesLabel.SetText(fmt.Sprintf(T("retriesLabel"), details.Retries))
+	}
+	dp.updateHistory(details)
+
 	dp.container.Refresh()
 }
 
+// updateHistory affiche les dates du dernier transfert et sa dernière erreur
+func (dp *DetailsPanel) updateHistory(download *downloader.Download) {
+	dp.startedLabel.SetText(fmt.Sprintf(T("startedAtLabel"), formatTime(download.StartedAt)))
+	dp.finishedLabel.SetText(fmt.Sprintf(T("finishedAtLabel"), formatTime(download.FinishedAt)))
+
+	if download.LastError == "" {
+		dp.lastErrorLabel.Hide()
+		return
+	}
+	dp.lastErrorLabel.SetText(fmt.Sprintf(T("lastErrorLabel"), download.LastError))
+	dp.lastErrorLabel.Show()
+}
+
+// addStatusHistory affiche les derniers changements de statut et leur cause
+func (dp *DetailsPanel) addStatusHistory(id int64) {
+	transitions, err := dp.ui.db.GetStatusTransitions(id)
+	if err != nil || len(transitions) == 0 {
+		return
+	}
+
+	dp.container.Add(widget.NewLabel(T("statusHistory")))
+	for _, transition := range transitions[max(0, len(transitions)-maxHistoryEntries):] {
+		label := widget.NewLabel(formatTransition(transition))
+		label.Wrapping = fyne.TextWrapWord
+		dp.container.Add(label)
+	}
+}
+
 func (dp *DetailsPanel) setVSplitOffset(offset float64) {
 	if content, ok := dp.ui.window.Content().(*fyne.Container); ok {
 		for _, obj := range content.Objects {
@@ -143,6 +261,30 @@ func (dp *DetailsPanel) setVSplitOffset(offset float64) {
 	}
 }
 
+// downloadedRatio retourne la part téléchargée, 0 si la taille est inconnue
+func downloadedRatio(download *downloader.Download) float64 {
+	if download.Size <= 0 {
+		return 0
+	}
+	return min(float64(download.DownloadedSize)/float64(download.Size), 1)
+}
+
+// formatTotalSize affiche la taille du fichier, qui peut ne pas être annoncée par le serveur
+func formatTotalSize(size int64) string {
+	if size <= 0 {
+		return T("unknownSize")
+	}
+	return formatSize(size)
+}
+
+// formatTime affiche une date enregistrée, qui peut être inconnue
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return T("notYet")
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
 func formatSize(size int64) string {
 	const unit = 1024
 	if size < unit {
@@ -156,23 +298,39 @@ func formatSize(size int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
-func formatStatus(status string) string {
+func formatStatus(status downloader.Status) string {
 	switch status {
-	case "pending":
+	case downloader.StatusPending:
 		return "En attente"
-	case "downloading":
+	case downloader.StatusDownloading:
 		return "En cours"
-	case "paused":
+	case downloader.StatusPaused:
 		return "En pause"
-	case "completed":
+	case downloader.StatusCompleted:
 		return "Terminé"
-	case "failed":
+	case downloader.StatusFailed:
 		return "Échoué"
+	case downloader.StatusCorrupted:
+		return "Corrompu"
+	case downloader.StatusCancelled:
+		return "Annulé"
 	default:
-		return status
+		return string(status)
+	}
+}
+
+func formatTransition(transition database.StatusTransition) string {
+	change := formatStatus(transition.To)
+	if transition.From != "" {
+		change = formatStatus(transition.From) + " → " + change
 	}
+	return fmt.Sprintf("%s  %s (%s)", formatTime(transition.Time), change, transition.Cause)
 }
 
-func getFileName(url string) string {
+// getFileName retourne le nom retenu pour le fichier, ou à défaut celui déduit de l'URL
+func getFileName(fileName, url string) string {
+	if fileName != "" {
+		return fileName
+	}
 	return filepath.Base(url)
 }
@@ -1,9 +1,11 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"gestionnaire-telechargement/internal/downloader"
 	"net/url"
-	"strconv"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -15,7 +17,7 @@ func showAddDownloadDialog(u *UI) {
 	clipboardContent := u.getClipboardContent()
 
 	urlEntry := widget.NewMultiLineEntry()
-	urlEntry.SetPlaceHolder("Entrez les URLs à télécharger (une par ligne)")
+	urlEntry.SetPlaceHolder(T("enterURLs"))
 
 	if clipboardContent != "" && isURL(clipboardContent) {
 		urlEntry.SetText(clipboardContent)
@@ -24,7 +26,7 @@ func showAddDownloadDialog(u *UI) {
 	pathEntry := widget.NewEntry()
 	pathEntry.SetText(u.downloader.DownloadDir)
 
-	pathButton := widget.NewButton("Choisir", func() {
+	pathButton := widget.NewButton(T("choose"), func() {
 		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
 			if err != nil {
 				dialog.ShowError(err, u.window)
@@ -40,50 +42,223 @@ func showAddDownloadDialog(u *UI) {
 
 	pathContainer := container.NewBorder(nil, nil, nil, pathButton, pathEntry)
 
+	// Politique propre à ces téléchargements, la politique globale par défaut
+	policyOptions := append([]string{T("collisionDefault")}, collisionPolicyLabels()...)
+	policySelect := widget.NewSelect(policyOptions, nil)
+	policySelect.SetSelected(T("collisionDefault"))
+
+	// Empreinte attendue, saisie directement ou via l'URL d'un fichier d'empreintes
+	checksumEntry := widget.NewEntry()
+	checksumEntry.SetPlaceHolder(T("checksumPlaceholder"))
+
+	prioritySelect := widget.NewSelect(priorityLabels(), nil)
+	prioritySelect.SetSelected(priorityLabel(downloader.PriorityNormal))
+
+	// Options HTTP exigées par certains serveurs, repliées par défaut
+	userAgentEntry := widget.NewEntry()
+	refererEntry := widget.NewEntry()
+	headersEntry := widget.NewMultiLineEntry()
+	headersEntry.SetPlaceHolder(T("requestHeadersPlaceholder"))
+	cookiesEntry := widget.NewMultiLineEntry()
+	cookiesEntry.SetPlaceHolder(T("cookiesPlaceholder"))
+	// Identifiants pour Basic, Digest ou Bearer ; à défaut, ceux enregistrés pour
+	// l'hôte ou ceux du fichier .netrc sont utilisés
+	usernameEntry := widget.NewEntry()
+	passwordEntry := widget.NewPasswordEntry()
+	tokenEntry := widget.NewPasswordEntry()
+	rememberCheck := widget.NewCheck(T("rememberCredentials"), nil)
+
+	httpOptions := widget.NewAccordion(
+		widget.NewAccordionItem(T("httpOptions"), container.NewVBox(
+			widget.NewLabel(T("userAgent")),
+			userAgentEntry,
+			widget.NewLabel(T("referer")),
+			refererEntry,
+			widget.NewLabel(T("requestHeaders")),
+			headersEntry,
+			widget.NewLabel(T("cookies")),
+			cookiesEntry,
+		)),
+		widget.NewAccordionItem(T("authentication"), container.NewVBox(
+			widget.NewLabel(T("username")),
+			usernameEntry,
+			widget.NewLabel(T("password")),
+			passwordEntry,
+			widget.NewLabel(T("bearerToken")),
+			tokenEntry,
+			rememberCheck,
+		)),
+	)
+
 	content := container.NewVBox(
-		widget.NewLabel("URLs à télécharger :"),
+		widget.NewLabel(T("urlsToDownload")),
 		urlEntry,
-		widget.NewLabel("Chemin de sauvegarde :"),
+		widget.NewLabel(T("savePath")),
 		pathContainer,
+		widget.NewLabel(T("collisionPolicy")),
+		policySelect,
+		widget.NewLabel(T("priority")),
+		prioritySelect,
+		widget.NewLabel(T("checksum")),
+		checksumEntry,
+		httpOptions,
 	)
 
-	dialog.ShowCustomConfirm("Ajouter des téléchargements", "Télécharger", "Annuler", content, func(download bool) {
+	dialog.ShowCustomConfirm(T("addDownloads"), T("startDownload"), T("cancel"), content, func(download bool) {
 		if download {
 			u.downloader.DownloadDir = pathEntry.Text
-			go u.downloadMultiple(urlEntry.Text) // Modifié ici
+			if err := downloader.ValidateChecksum(checksumEntry.Text); err != nil {
+				u.showError(T("errorTitle"), fmt.Sprintf(T("invalidChecksum"), err))
+				return
+			}
+			headers, err := downloader.ParseHeaders(headersEntry.Text)
+			if err != nil {
+				u.showError(T("errorTitle"), fmt.Sprintf(T("invalidHeaders"), err))
+				return
+			}
+			cookies, err := downloader.ParseCookies(cookiesEntry.Text)
+			if err != nil {
+				u.showError(T("errorTitle"), fmt.Sprintf(T("invalidCookies"), err))
+				return
+			}
+			options := downloader.HTTPOptions{
+				Headers:   headers,
+				Cookies:   cookies,
+				UserAgent: strings.TrimSpace(userAgentEntry.Text),
+				Referer:   strings.TrimSpace(refererEntry.Text),
+			}
+			creds := downloader.Credentials{
+				Username: strings.TrimSpace(usernameEntry.Text),
+				Password: passwordEntry.Text,
+				Token:    strings.TrimSpace(tokenEntry.Text),
+			}
+			if rememberCheck.Checked && !creds.IsZero() {
+				// Enregistrés pour les hôtes des URLs, ils valent aussi pour les téléchargements suivants
+				u.saveHostCredentials(urlEntry.Text, creds)
+				creds = downloader.Credentials{}
+			}
+			go u.downloadMultiple(urlEntry.Text, collisionPolicyFromLabel(policySelect.Selected), strings.TrimSpace(checksumEntry.Text), priorityFromLabel(prioritySelect.Selected), options, creds) // Modifié ici
 		}
 	}, u.window)
 }
 
-func isURL(s string) bool {
-	if _, err := url.ParseRequestURI(s); err != nil {
-		return false
+// priorities liste les priorités dans l'ordre d'affichage
+var priorities = []downloader.Priority{
+	downloader.PriorityHigh,
+	downloader.PriorityNormal,
+	downloader.PriorityLow,
+}
+
+func priorityLabel(priority downloader.Priority) string {
+	switch priority {
+	case downloader.PriorityHigh:
+		return T("priorityHigh")
+	case downloader.PriorityLow:
+		return T("priorityLow")
+	default:
+		return T("priorityNormal")
 	}
-	return true
 }
 
-func showSettingsDialog(u *UI) {
-	pathEntry := widget.NewEntry()
-	pathEntry.SetText(u.downloader.DownloadDir)
+func priorityLabels() []string {
+	labels := make([]string, len(priorities))
+	for i, priority := range priorities {
+		labels[i] = priorityLabel(priority)
+	}
+	return labels
+}
+
+func priorityFromLabel(label string) downloader.Priority {
+	for _, priority := range priorities {
+		if priorityLabel(priority) == label {
+			return priority
+		}
+	}
+	return downloader.PriorityNormal
+}
 
-	chunkEntry := widget.NewEntry()
-	chunkEntry.SetText(strconv.Itoa(u.downloader.MaxConcurrent))
+// collisionPolicies liste les politiques de collision dans l'ordre d'affichage
+var collisionPolicies = []downloader.CollisionPolicy{
+	downloader.CollisionRename,
+	downloader.CollisionOverwrite,
+	downloader.CollisionSkip,
+	downloader.CollisionAsk,
+}
 
-	content := container.NewVBox(
-		widget.NewLabel("Chemin de sauvegarde :"),
-		pathEntry,
-		widget.NewLabel("Nombre de téléchargements simultanés :"),
-		chunkEntry,
-	)
+func collisionPolicyLabel(policy downloader.CollisionPolicy) string {
+	switch policy {
+	case downloader.CollisionOverwrite:
+		return T("collisionOverwrite")
+	case downloader.CollisionSkip:
+		return T("collisionSkip")
+	case downloader.CollisionAsk:
+		return T("collisionAsk")
+	default:
+		return T("collisionRename")
+	}
+}
 
-	dialog.ShowCustomConfirm("Paramètres", "Enregistrer", "Annuler", content, func(save bool) {
-		if save {
-			u.downloader.DownloadDir = pathEntry.Text
-			maxConcurrent, _ := strconv.Atoi(chunkEntry.Text)
-			u.downloader.MaxConcurrent = maxConcurrent
-			u.downloader.UpdateSemaphore()
+func collisionPolicyLabels() []string {
+	labels := make([]string, len(collisionPolicies))
+	for i, policy := range collisionPolicies {
+		labels[i] = collisionPolicyLabel(policy)
+	}
+	return labels
+}
+
+// collisionPolicyFromLabel retourne la politique affichée sous ce libellé, ou ""
+// (politique globale) si le libellé n'en désigne aucune
+func collisionPolicyFromLabel(label string) downloader.CollisionPolicy {
+	for _, policy := range collisionPolicies {
+		if collisionPolicyLabel(policy) == label {
+			return policy
 		}
-	}, u.window)
+	}
+	return ""
+}
+
+// askCollisionPolicy demande à l'utilisateur que faire du fichier existant ; appelé
+// depuis la goroutine du téléchargement, il attend la réponse ou l'annulation de ctx
+func askCollisionPolicy(ctx context.Context, u *UI, filePath string) (downloader.CollisionPolicy, error) {
+	choice := make(chan downloader.CollisionPolicy, 1)
+
+	message := widget.NewLabel(fmt.Sprintf(T("fileExistsMessage"), filePath))
+	message.Wrapping = fyne.TextWrapWord
+	d := dialog.NewCustomWithoutButtons(T("fileExistsTitle"), message, u.window)
+
+	choose := func(policy downloader.CollisionPolicy) func() {
+		return func() {
+			d.Hide()
+			choice <- policy
+		}
+	}
+	overwriteButton := widget.NewButton(T("overwrite"), choose(downloader.CollisionOverwrite))
+	overwriteButton.Importance = widget.DangerImportance
+	renameButton := widget.NewButton(T("rename"), choose(downloader.CollisionRename))
+	renameButton.Importance = widget.HighImportance
+	d.SetButtons([]fyne.CanvasObject{
+		overwriteButton,
+		renameButton,
+		widget.NewButton(T("skip"), choose(downloader.CollisionSkip)),
+	})
+	d.Resize(fyne.NewSize(400, 0))
+	d.Show()
+
+	select {
+	case policy := <-choice:
+		return policy, nil
+	case <-ctx.Done():
+		// Téléchargement mis en pause, annulé ou programme arrêté : la question n'a plus lieu d'être
+		d.Hide()
+		return "", context.Cause(ctx)
+	}
+}
+
+func isURL(s string) bool {
+	if _, err := url.ParseRequestURI(s); err != nil {
+		return false
+	}
+	return true
 }
 
 func showError(u *UI, title, message string) {
@@ -102,12 +277,12 @@ func resumeDownloads(u *UI) {
 		return
 	}
 
-	pendingUrls := make([]string, len(pendings))
+	pendingIDs := make([]int64, len(pendings))
 	for i, download := range pendings {
-		pendingUrls[i] = download.URL
+		pendingIDs[i] = download.ID
 	}
 
-	err = u.downloader.ResumePendingDownloads(pendingUrls)
+	err = u.downloader.ResumePendingDownloads(pendingIDs)
 	if err != nil {
 		showError(u, "Erreur de reprise", fmt.Sprintf("Impossible de reprendre les téléchargements : %v", err))
 	} else {
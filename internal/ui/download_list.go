@@ -2,7 +2,7 @@ package ui
 
 import (
 	"fmt"
-	"path/filepath"
+	"gestionnaire-telechargement/internal/downloader"
 	"strings"
 	"sync"
 	"time"
@@ -17,8 +17,8 @@ import (
 type DownloadList struct {
 	ui             *UI
 	container      *fyne.Container
-	downloads      map[string]*downloadItem
-	downloadSpeeds map[string]float64
+	downloads      map[int64]*downloadItem
+	downloadSpeeds map[int64]float64
 	downloadsMutex sync.Mutex
 	allDownloads   []*downloadItem // Ajoutez ce champ
 }
@@ -29,8 +29,8 @@ func NewDownloadList(ui *UI) *DownloadList {
 	dl := &DownloadList{
 		ui:             ui,
 		container:      container.NewVBox(),
-		downloads:      make(map[string]*downloadItem),
-		downloadSpeeds: make(map[string]float64),
+		downloads:      make(map[int64]*downloadItem),
+		downloadSpeeds: make(map[int64]float64),
 		allDownloads:   make([]*downloadItem, 0),
 	}
 	// Déplacez loadExistingDownloads dans une méthode séparée
@@ -50,36 +50,44 @@ func (dl *DownloadList) loadExistingDownloads() {
 	}
 
 	for _, download := range downloads {
-		dl.addDownloadProgressToList(download.URL, download.Status)
+		dl.addDownloadProgressToList(download.ID, download.URL, download.FileName, download.Status)
 	}
 }
 
-func (dl *DownloadList) addDownloadProgressToList(url, status string) {
+func (dl *DownloadList) addDownloadProgressToList(id int64, url, fileName string, status downloader.Status) {
 	dl.downloadsMutex.Lock()
 	defer dl.downloadsMutex.Unlock()
 
 	progress := widget.NewProgressBar()
-	if status == "completed" {
+	if status == downloader.StatusCompleted {
 		progress.SetValue(1)
 	}
+	infinite := widget.NewProgressBarInfinite()
+	infinite.Hide()
 
 	deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
-		dl.deleteDownload(url)
+		dl.deleteDownload(id)
 	})
 	deleteButton.Importance = widget.LowImportance
 
-	label := widget.NewLabel(filepath.Base(url))
+	label := widget.NewLabel(getFileName(fileName, url))
 
 	detailsButton := widget.NewButtonWithIcon("", theme.InfoIcon(), func() {
-		dl.ui.detailsPanel.showDownloadDetails(url)
+		dl.ui.detailsPanel.showDownloadDetails(id)
 	})
 	detailsButton.Importance = widget.LowImportance
 
 	pauseResumeButton := widget.NewButtonWithIcon("", theme.MediaPauseIcon(), func() {
-		dl.togglePauseResume(url)
+		dl.togglePauseResume(id)
 	})
 	pauseResumeButton.Importance = widget.LowImportance
 
+	var queueButton *widget.Button
+	queueButton = widget.NewButtonWithIcon("", theme.MenuIcon(), func() {
+		dl.showQueueMenu(id, queueButton)
+	})
+	queueButton.Importance = widget.LowImportance
+
 	speedLabel := widget.NewLabel("0 B/s")
 
 	item := container.NewBorder(
@@ -90,11 +98,12 @@ func (dl *DownloadList) addDownloadProgressToList(url, status string) {
 			speedLabel,
 		),
 		container.NewHBox(
+			queueButton,
 			pauseResumeButton,
 			detailsButton,
 			deleteButton,
 		),
-		progress,
+		container.NewStack(progress, infinite),
 	)
 
 	card := widget.NewCard("", "", item)
@@ -102,94 +111,180 @@ func (dl *DownloadList) addDownloadProgressToList(url, status string) {
 
 	downloadItem := &downloadItem{
 		progressBar:       progress,
+		infiniteBar:       infinite,
+		nameLabel:         label,
+		nameResolved:      fileName != "",
 		status:            status,
 		speedLabel:        speedLabel,
 		lastUpdate:        time.Now(),
 		lastSize:          0,
 		pauseResumeButton: pauseResumeButton,
+		id:                id,
 		url:               url,
 		card:              card,
 	}
 
-	dl.downloads[url] = downloadItem
+	dl.downloads[id] = downloadItem
 	dl.allDownloads = append(dl.allDownloads, downloadItem)
 
-	dl.updatePauseResumeButton(url)
+	dl.updatePauseResumeButton(id)
 }
 
-func (dl *DownloadList) togglePauseResume(url string) {
+// showQueueMenu propose de déplacer le téléchargement dans la file d'attente
+func (dl *DownloadList) showQueueMenu(id int64, anchor fyne.CanvasObject) {
+	moves := []struct {
+		label string
+		move  downloader.QueueMove
+	}{
+		{T("moveToTop"), downloader.MoveToTop},
+		{T("moveUp"), downloader.MoveUp},
+		{T("moveDown"), downloader.MoveDown},
+		{T("moveToBottom"), downloader.MoveToBottom},
+	}
+
+	items := make([]*fyne.MenuItem, len(moves))
+	for i, m := range moves {
+		move := m.move
+		items[i] = fyne.NewMenuItem(m.label, func() {
+			dl.moveInQueue(id, move)
+		})
+	}
+
+	canvas := fyne.CurrentApp().Driver().CanvasForObject(anchor)
+	widget.ShowPopUpMenuAtRelativePosition(fyne.NewMenu("", items...), canvas, fyne.NewPos(0, anchor.Size().Height), anchor)
+}
+
+// moveInQueue déplace le téléchargement dans la file puis réordonne la liste en conséquence
+func (dl *DownloadList) moveInQueue(id int64, move downloader.QueueMove) {
+	if !dl.ui.downloader.MoveInQueue(id, move) {
+		showInfo(dl.ui, T("notQueuedTitle"), T("notQueuedMessage"))
+		return
+	}
+	dl.applyQueueOrder(dl.ui.downloader.QueueOrder())
+}
+
+// applyQueueOrder range les téléchargements en attente dans l'ordre de la file,
+// aux emplacements qu'ils occupent déjà, sans déplacer les autres
+func (dl *DownloadList) applyQueueOrder(order []int64) {
 	dl.downloadsMutex.Lock()
 	defer dl.downloadsMutex.Unlock()
 
-	if item, exists := dl.downloads[url]; exists {
-		var err error
-		var action string
-		if item.status == "paused" {
-			err = dl.ui.downloader.ResumeDownload(url)
-			action = "reprendre"
-			if err == nil {
-				item.status = "downloading"
-			}
-		} else if item.status == "downloading" || item.status == "pending" {
-			err = dl.ui.downloader.PauseDownload(url)
-			action = "mettre en pause"
-			if err == nil {
-				item.status = "paused"
-			}
+	queued := make([]*downloadItem, 0, len(order))
+	cards := make(map[fyne.CanvasObject]bool, len(order))
+	for _, id := range order {
+		if item, exists := dl.downloads[id]; exists {
+			queued = append(queued, item)
+			cards[item.card] = true
+		}
+	}
+
+	next := 0
+	for i, item := range dl.allDownloads {
+		if cards[item.card] {
+			dl.allDownloads[i] = queued[next]
+			next++
 		}
+	}
 
-		if err != nil {
-			showError(dl.ui, "Erreur", fmt.Sprintf("Impossible de %s le téléchargement : %v", action, err))
-		} else {
-			dl.updatePauseResumeButton(url)
+	next = 0
+	for i, object := range dl.container.Objects {
+		if cards[object] {
+			dl.container.Objects[i] = queued[next].card
+			next++
 		}
 	}
+	dl.container.Refresh()
 }
 
-func (dl *DownloadList) updatePauseResumeButton(url string) {
-	if item, exists := dl.downloads[url]; exists {
+func (dl *DownloadList) togglePauseResume(id int64) {
+	dl.downloadsMutex.Lock()
+	item, exists := dl.downloads[id]
+	var status downloader.Status
+	if exists {
+		status = item.status
+	}
+	dl.downloadsMutex.Unlock()
+
+	// La pause attend l'arrêt du téléchargement, qui met à jour la liste : le
+	// verrou ne doit pas être détenu pendant l'appel
+	var err error
+	var action string
+	var newStatus downloader.Status
+	switch status {
+	case downloader.StatusPaused:
+		err = dl.ui.downloader.ResumeDownload(id)
+		action, newStatus = "reprendre", downloader.StatusPending
+	case downloader.StatusDownloading, downloader.StatusPending:
+		err = dl.ui.downloader.PauseDownload(id)
+		action, newStatus = "mettre en pause", downloader.StatusPaused
+	default:
+		return
+	}
+
+	if err != nil {
+		showError(dl.ui, "Erreur", fmt.Sprintf("Impossible de %s le téléchargement : %v", action, err))
+		return
+	}
+
+	dl.downloadsMutex.Lock()
+	defer dl.downloadsMutex.Unlock()
+
+	// Un téléchargement terminé avant que la pause ne prenne effet reste terminé
+	item.setStatus(newStatus)
+	dl.updatePauseResumeButton(id)
+}
+
+func (dl *DownloadList) updatePauseResumeButton(id int64) {
+	if item, exists := dl.downloads[id]; exists {
 		switch item.status {
-		case "paused":
+		case downloader.StatusPaused:
 			item.pauseResumeButton.SetIcon(theme.MediaPlayIcon())
 			item.pauseResumeButton.Show()
-		case "downloading", "pending":
+		case downloader.StatusDownloading, downloader.StatusPending:
 			item.pauseResumeButton.SetIcon(theme.MediaPauseIcon())
 			item.pauseResumeButton.Show()
-		case "completed":
+		case downloader.StatusCompleted:
 			item.pauseResumeButton.Hide()
 		}
 	}
 }
 
-func (dl *DownloadList) updateProgress(url string, progress float64) {
+// updateProgress affiche l'avancement reçu du downloader ; la vitesse est
+// calculée à partir des octets reçus, sans interroger la base à chaque événement
+func (dl *DownloadList) updateProgress(id int64, progress float64, downloaded int64) {
 	dl.downloadsMutex.Lock()
 	defer dl.downloadsMutex.Unlock()
 
 	now := time.Now()
 
-	if item, exists := dl.downloads[url]; exists {
-		if item.status != "paused" {
-			item.progressBar.SetValue(progress)
-			if progress >= 1 {
-				item.status = "completed"
-				dl.updatePauseResumeButton(url)
+	if item, exists := dl.downloads[id]; exists {
+		if item.status != downloader.StatusPaused {
+			setItemProgress(item, progress)
+			if progress >= 1 && item.setStatus(downloader.StatusCompleted) {
+				dl.updatePauseResumeButton(id)
 			}
 
-			details, err := dl.ui.db.GetDownloadDetails(url)
-			if err == nil {
-				if now.Sub(item.lastUpdate) >= speedUpdateInterval {
-					elapsed := now.Sub(item.lastUpdate).Seconds()
-					sizeDiff := float64(details.Size) - item.lastSize
+			// Le nom définitif n'est connu qu'une fois la réponse du serveur reçue ;
+			// la base n'est consultée que jusqu'à ce qu'il le soit
+			if !item.nameResolved {
+				if details, err := dl.ui.db.GetDownloadDetails(id); err == nil && details.FileName != "" {
+					item.nameLabel.SetText(details.FileName)
+					item.nameResolved = true
+				}
+			}
 
-					if elapsed > 0 && sizeDiff > 0 {
-						speed := sizeDiff / elapsed
-						dl.downloadSpeeds[url] = speed
-						item.speedLabel.SetText(formatSpeed(speed))
-					}
+			if now.Sub(item.lastUpdate) >= speedUpdateInterval {
+				elapsed := now.Sub(item.lastUpdate).Seconds()
+				sizeDiff := float64(downloaded) - item.lastSize
 
-					item.lastUpdate = now
-					item.lastSize = float64(details.Size)
+				if elapsed > 0 && sizeDiff > 0 {
+					speed := sizeDiff / elapsed
+					dl.downloadSpeeds[id] = speed
+					item.speedLabel.SetText(formatSpeed(speed))
 				}
+
+				item.lastUpdate = now
+				item.lastSize = float64(downloaded)
 			}
 		}
 	}
@@ -197,18 +292,48 @@ func (dl *DownloadList) updateProgress(url string, progress float64) {
 	dl.ui.updateGlobalSpeed()
 }
 
-func (dl *DownloadList) updateDownloadStatus(url, status string) {
+func (dl *DownloadList) updateDownloadStatus(id int64, status downloader.Status) {
 	dl.downloadsMutex.Lock()
 	defer dl.downloadsMutex.Unlock()
 
-	if item, exists := dl.downloads[url]; exists {
-		item.status = status
-		if status == "completed" {
-			item.progressBar.SetValue(1)
+	if item, exists := dl.downloads[id]; exists {
+		if !item.setStatus(status) {
+			return
+		}
+		dl.updatePauseResumeButton(id)
+		switch {
+		case status == downloader.StatusCompleted:
+			setItemProgress(item, 1)
+		case status != downloader.StatusDownloading && status != downloader.StatusPending && item.infiniteBar.Visible():
+			// Sans taille connue, un téléchargement interrompu repartira du début
+			setItemProgress(item, 0)
 		}
 	}
 }
 
+// setStatus change le statut affiché si la table des transitions du downloader
+// le permet : un événement en retard ne remet pas en cause un statut plus récent
+func (item *downloadItem) setStatus(status downloader.Status) bool {
+	if downloader.CheckTransition(item.status, status) != nil {
+		return false
+	}
+	item.status = status
+	return true
+}
+
+// setItemProgress affiche la progression, ou une progression indéterminée
+// lorsque la taille du fichier est inconnue
+func setItemProgress(item *downloadItem, progress float64) {
+	if progress < 0 {
+		item.progressBar.Hide()
+		item.infiniteBar.Show()
+		return
+	}
+	item.infiniteBar.Hide()
+	item.progressBar.Show()
+	item.progressBar.SetValue(progress)
+}
+
 // Ajoutez cette nouvelle méthode
 func (dl *DownloadList) filterDownloads(searchTerm, filter string) {
 	dl.container.RemoveAll()
@@ -226,13 +351,13 @@ func (dl *DownloadList) filterDownloads(searchTerm, filter string) {
 
 			switch filter {
 			case T("inProgress"):
-				showItem = showItem && (item.status == "downloading" || item.status == "pending")
+				showItem = showItem && (item.status == downloader.StatusDownloading || item.status == downloader.StatusPending)
 			case T("completed"):
-				showItem = showItem && item.status == "completed"
+				showItem = showItem && item.status == downloader.StatusCompleted
 			case T("deleted"):
-				showItem = showItem && item.status == "deleted"
+				showItem = showItem && item.status == downloader.StatusDeleted
 			case T("errors"):
-				showItem = showItem && item.status == "failed"
+				showItem = showItem && (item.status == downloader.StatusFailed || item.status == downloader.StatusCorrupted)
 			}
 
 			if showItem {
@@ -244,65 +369,22 @@ func (dl *DownloadList) filterDownloads(searchTerm, filter string) {
 	dl.container.Refresh()
 }
 
-func (dl *DownloadList) deleteDownload(url string) {
-	dialog.ShowConfirm("Supprimer le téléchargement", "Voulez-vous supprimer ce téléchargement ?", func(shouldDelete bool) {
-		if shouldDelete {
-			err := dl.ui.downloader.SetDownloadStatusDeleted(url)
-			if err != nil {
-				dialog.ShowError(fmt.Errorf("Impossible de supprimer le téléchargement : %v", err), dl.ui.window)
-				return
-			}
-			if item, exists := dl.downloads[url]; exists {
-				item.status = "deleted"
-				dl.updatePauseResumeButton(url)
-			}
-			dl.filterDownloads("", "Tous les téléchargements")
-		}
-	}, dl.ui.window)
-}
-
-// Gardez cette définition et supprimez l'autre
-func showDeleteConfirmDialog(u *UI, url string) {
-	dialog.ShowConfirm("Supprimer le téléchargement", "Voulez-vous aussi supprimer le fichier local ?", func(deleteFile bool) {
-		err := u.downloader.DeleteDownload(url, deleteFile)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("Impossible de supprimer le téléchargement : %v", err), u.window)
+// deleteDownload retire le téléchargement de la liste après confirmation ; le
+// fichier téléchargé n'est supprimé que si l'utilisateur le demande
+func (dl *DownloadList) deleteDownload(id int64) {
+	deleteFile := widget.NewCheck(T("deleteLocalFile"), nil)
+	content := container.NewVBox(widget.NewLabel(T("deleteConfirmMessage")), deleteFile)
+	dialog.ShowCustomConfirm(T("deleteConfirmTitle"), T("delete"), T("cancel"), content, func(shouldDelete bool) {
+		if !shouldDelete {
 			return
 		}
-		u.downloadList.removeDownloadFromList(url)
-	}, u.window)
-}
-
-func (dl *DownloadList) removeDownloadFromList(url string) {
-	dl.downloadsMutex.Lock()
-	defer dl.downloadsMutex.Unlock()
-
-	if item, exists := dl.downloads[url]; exists {
-		dl.container.Remove(item.card)
-		delete(dl.downloads, url)
-	}
-	dl.container.Refresh()
-}
-
-func (dl *DownloadList) updateDownloadSpeed(url string) {
-	dl.downloadsMutex.Lock()
-	defer dl.downloadsMutex.Unlock()
-
-	if item, exists := dl.ui.downloads[url]; exists {
-		details, err := dl.ui.db.GetDownloadDetails(url)
-		if err != nil {
+		if err := dl.ui.downloader.DeleteDownload(id, deleteFile.Checked); err != nil {
+			dialog.ShowError(fmt.Errorf("Impossible de supprimer le téléchargement : %v", err), dl.ui.window)
 			return
 		}
-
-		now := time.Now()
-		if now.Sub(item.lastUpdate) >= speedUpdateInterval {
-			speed := float64(details.Size) - float64(item.lastSize)/now.Sub(item.lastUpdate).Seconds()
-			item.speedLabel.SetText(formatSpeed(speed))
-			item.lastUpdate = now
-			item.lastSize = float64(details.Size)
-
-			dl.downloadSpeeds[url] = speed
-			dl.ui.updateGlobalSpeed()
+		if item, exists := dl.downloads[id]; exists && item.setStatus(downloader.StatusDeleted) {
+			dl.updatePauseResumeButton(id)
 		}
-	}
+		dl.filterDownloads("", "Tous les téléchargements")
+	}, dl.ui.window)
 }
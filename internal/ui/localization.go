@@ -19,7 +19,8 @@ var translations = map[language.Tag]map[string]string{
 		"errors":                    "Errors",
 		"deleteConfirmTitle":        "Delete Download",
 		"deleteConfirmMessage":      "Do you want to delete this download?",
-		"deleteFileConfirmMessage":  "Do you also want to delete the local file?",
+		"deleteLocalFile":           "Also delete the downloaded file",
+		"delete":                    "Delete",
 		"errorTitle":                "Error",
 		"invalidURL":                "The following URL is invalid: ",
 		"noValidURL":                "Please enter at least one valid URL.",
@@ -43,6 +44,95 @@ var translations = map[language.Tag]map[string]string{
 		"errorSavingSettings":       "Error saving settings",
 		"settingsSaved":             "Settings saved",
 		"settingsSavedMessage":      "Your settings have been saved successfully.",
+		"collisionPolicy":           "If the file already exists",
+		"collisionDefault":          "Use global setting",
+		"collisionOverwrite":        "Overwrite",
+		"collisionRename":           "Rename automatically",
+		"collisionSkip":             "Skip if identical",
+		"collisionAsk":              "Ask me",
+		"fileExistsTitle":           "File already exists",
+		"fileExistsMessage":         "The file %s already exists. What do you want to do?",
+		"overwrite":                 "Overwrite",
+		"rename":                    "Rename",
+		"skip":                      "Skip",
+		"retriesLabel":              "Retries: %d",
+		"createdAtLabel":            "Added: %s",
+		"startedAtLabel":            "Started: %s",
+		"finishedAtLabel":           "Finished: %s",
+		"lastErrorLabel":            "Last error: %s",
+		"notYet":                    "—",
+		"statusHistory":             "Status history:",
+		"globalSpeedLimited":        "Global speed: %s (limit: %s)",
+		"speedLimitGlobal":          "Global speed limit (KB/s, 0 = unlimited)",
+		"speedLimitDownload":        "Speed limit for this download (KB/s, 0 = unlimited)",
+		"apply":                     "Apply",
+		"checksum":                  "Expected checksum (optional)",
+		"checksumPlaceholder":       "sha256:<hex>, or URL of a .sha256 / SHA256SUMS file",
+		"invalidChecksum":           "Invalid checksum: %v",
+		"maxConcurrent":             "Simultaneous downloads",
+		"priority":                  "Priority",
+		"priorityLow":               "Low",
+		"priorityNormal":            "Normal",
+		"priorityHigh":              "High",
+		"moveUp":                    "Move up",
+		"moveDown":                  "Move down",
+		"moveToTop":                 "Move to top",
+		"moveToBottom":              "Move to bottom",
+		"notQueuedTitle":            "Queue",
+		"notQueuedMessage":          "Only downloads waiting for a slot can be reordered.",
+		"hostMaxDownloads":          "Simultaneous downloads per host (0 = unlimited)",
+		"hostMaxConnections":        "Connections per host (0 = unlimited)",
+		"hostLimitOverrides":        "Per-host rules, one per line: pattern downloads connections",
+		"invalidHostLimits":         "Invalid per-host rules: %v",
+		"retryMaxAttempts":          "Maximum attempts",
+		"retryBaseDelay":            "Initial retry delay (seconds)",
+		"retryMaxDelay":             "Maximum retry delay (seconds)",
+		"retryJitter":               "Retry delay jitter (%)",
+		"unknownSize":               "Unknown",
+		"httpOptions":               "HTTP options",
+		"userAgent":                 "User agent",
+		"referer":                   "Referer",
+		"requestHeaders":            "Headers, one per line: Name: value",
+		"requestHeadersPlaceholder": "X-Api-Key: value",
+		"cookies":                   "Cookies",
+		"cookiesPlaceholder":        "name=value; other=value",
+		"invalidHeaders":            "Invalid headers: %v",
+		"invalidCookies":            "Invalid cookies: %v",
+		"authentication":            "Authentication",
+		"username":                  "Username",
+		"password":                  "Password",
+		"bearerToken":               "Bearer token",
+		"rememberCredentials":       "Remember for these hosts",
+		"storedCredentials":         "Stored credentials",
+		"noStoredCredentials":       "No stored credentials",
+		"selectHost":                "Select a host",
+		"forgetCredentials":         "Forget",
+		"proxy":                     "Proxy (empty = system settings, \"direct\" = none)",
+		"proxyPlaceholder":          "http://host:3128 or socks5://host:1080",
+		"proxyUsername":             "Proxy username",
+		"proxyPassword":             "Proxy password",
+		"proxyRules":                "Per-host proxies (pattern proxy-or-direct per line)",
+		"invalidProxy":              "Invalid proxy: %v",
+		"tlsCAFiles":                "Additional CA files (one PEM file per line)",
+		"tlsClientCerts":            "Client certificates (pattern certificate key per line)",
+		"tlsPins":                   "Pinned public keys (pattern sha256//hash per line)",
+		"tlsInsecureHosts":          "Hosts whose certificate is not verified (one pattern per line)",
+		"invalidTLSSettings":        "Invalid TLS configuration: %v",
+		"invalidSetting":            "%s: invalid value \"%s\"",
+		"minFreeSpace":              "Pause downloads below this free disk space (MB, 0 = never)",
+		"insufficientSpace":         "Not enough disk space in %s.",
+		"insufficientSpaceDetails":  "Not enough disk space in %s: %s needed, %s available.",
+		"lowDiskSpaceTitle":         "Low disk space",
+		"lowDiskSpaceMessage":       "Only %s left on the download disk: %d download(s) paused until space is freed.",
+		"diskSpaceRecoveredTitle":   "Disk space available",
+		"diskSpaceRecoveredMessage": "Enough disk space again: %d download(s) resumed.",
+		"settingsGeneral":           "General",
+		"settingsNetwork":           "Network",
+		"settingsSecurity":          "Security",
+		"urlsToDownload":            "URLs to download:",
+		"savePath":                  "Save to:",
+		"addDownloads":              "Add Downloads",
+		"startDownload":             "Download",
 	},
 	language.French: {
 		"windowTitle":               "Gestionnaire de téléchargement",
@@ -56,7 +146,8 @@ var translations = map[language.Tag]map[string]string{
 		"errors":                    "Erreurs",
 		"deleteConfirmTitle":        "Supprimer le téléchargement",
 		"deleteConfirmMessage":      "Voulez-vous supprimer ce téléchargement ?",
-		"deleteFileConfirmMessage":  "Voulez-vous aussi supprimer le fichier local ?",
+		"deleteLocalFile":           "Supprimer aussi le fichier téléchargé",
+		"delete":                    "Supprimer",
 		"errorTitle":                "Erreur",
 		"invalidURL":                "L'URL suivante est invalide : ",
 		"noValidURL":                "Veuillez entrer au moins une URL valide.",
@@ -80,6 +171,95 @@ var translations = map[language.Tag]map[string]string{
 		"errorSavingSettings":       "Erreur lors de l'enregistrement des paramètres",
 		"settingsSaved":             "Paramètres enregistrés",
 		"settingsSavedMessage":      "Vos paramètres ont été enregistrés avec succès.",
+		"collisionPolicy":           "Si le fichier existe déjà",
+		"collisionDefault":          "Utiliser le paramètre global",
+		"collisionOverwrite":        "Écraser",
+		"collisionRename":           "Renommer automatiquement",
+		"collisionSkip":             "Ignorer si identique",
+		"collisionAsk":              "Me demander",
+		"fileExistsTitle":           "Le fichier existe déjà",
+		"fileExistsMessage":         "Le fichier %s existe déjà. Que voulez-vous faire ?",
+		"overwrite":                 "Écraser",
+		"rename":                    "Renommer",
+		"skip":                      "Ignorer",
+		"retriesLabel":              "Nouvelles tentatives : %d",
+		"createdAtLabel":            "Ajouté le : %s",
+		"startedAtLabel":            "Démarré le : %s",
+		"finishedAtLabel":           "Terminé le : %s",
+		"lastErrorLabel":            "Dernière erreur : %s",
+		"notYet":                    "—",
+		"statusHistory":             "Historique des statuts :",
+		"globalSpeedLimited":        "Vitesse globale : %s (limite : %s)",
+		"speedLimitGlobal":          "Limite de débit globale (Ko/s, 0 = illimitée)",
+		"speedLimitDownload":        "Limite de débit de ce téléchargement (Ko/s, 0 = illimitée)",
+		"apply":                     "Appliquer",
+		"checksum":                  "Empreinte attendue (facultative)",
+		"checksumPlaceholder":       "sha256:<hex>, ou URL d'un fichier .sha256 / SHA256SUMS",
+		"invalidChecksum":           "Empreinte invalide : %v",
+		"maxConcurrent":             "Téléchargements simultanés",
+		"priority":                  "Priorité",
+		"priorityLow":               "Basse",
+		"priorityNormal":            "Normale",
+		"priorityHigh":              "Haute",
+		"moveUp":                    "Monter",
+		"moveDown":                  "Descendre",
+		"moveToTop":                 "Placer en tête",
+		"moveToBottom":              "Placer en dernier",
+		"notQueuedTitle":            "File d'attente",
+		"notQueuedMessage":          "Seuls les téléchargements en attente d'une place peuvent être déplacés.",
+		"hostMaxDownloads":          "Téléchargements simultanés par hôte (0 = illimité)",
+		"hostMaxConnections":        "Connexions par hôte (0 = illimité)",
+		"hostLimitOverrides":        "Règles par hôte, une par ligne : motif téléchargements connexions",
+		"invalidHostLimits":         "Règles par hôte invalides : %v",
+		"retryMaxAttempts":          "Nombre maximal de tentatives",
+		"retryBaseDelay":            "Délai initial avant nouvelle tentative (secondes)",
+		"retryMaxDelay":             "Délai maximal avant nouvelle tentative (secondes)",
+		"retryJitter":               "Variation aléatoire du délai (%)",
+		"unknownSize":               "Inconnue",
+		"httpOptions":               "Options HTTP",
+		"userAgent":                 "User-Agent",
+		"referer":                   "Referer",
+		"requestHeaders":            "En-têtes, un par ligne : Nom: valeur",
+		"requestHeadersPlaceholder": "X-Api-Key: valeur",
+		"cookies":                   "Cookies",
+		"cookiesPlaceholder":        "nom=valeur; autre=valeur",
+		"invalidHeaders":            "En-têtes invalides : %v",
+		"invalidCookies":            "Cookies invalides : %v",
+		"authentication":            "Authentification",
+		"username":                  "Nom d'utilisateur",
+		"password":                  "Mot de passe",
+		"bearerToken":               "Jeton Bearer",
+		"rememberCredentials":       "Enregistrer pour ces hôtes",
+		"storedCredentials":         "Identifiants enregistrés",
+		"noStoredCredentials":       "Aucun identifiant enregistré",
+		"selectHost":                "Choisir un hôte",
+		"forgetCredentials":         "Oublier",
+		"proxy":                     "Proxy (vide = paramètres du système, « direct » = aucun)",
+		"proxyPlaceholder":          "http://hôte:3128 ou socks5://hôte:1080",
+		"proxyUsername":             "Utilisateur du proxy",
+		"proxyPassword":             "Mot de passe du proxy",
+		"proxyRules":                "Proxys par hôte (motif proxy-ou-direct par ligne)",
+		"invalidProxy":              "Proxy invalide : %v",
+		"tlsCAFiles":                "Autorités de certification supplémentaires (un fichier PEM par ligne)",
+		"tlsClientCerts":            "Certificats clients (motif certificat clé par ligne)",
+		"tlsPins":                   "Clés publiques épinglées (motif sha256//empreinte par ligne)",
+		"tlsInsecureHosts":          "Hôtes dont le certificat n'est pas vérifié (un motif par ligne)",
+		"invalidTLSSettings":        "Configuration TLS invalide : %v",
+		"invalidSetting":            "%s : valeur invalide « %s »",
+		"minFreeSpace":              "Mettre en pause sous cet espace disque libre (Mo, 0 = jamais)",
+		"insufficientSpace":         "Espace disque insuffisant dans %s.",
+		"insufficientSpaceDetails":  "Espace disque insuffisant dans %s : %s nécessaires, %s disponibles.",
+		"lowDiskSpaceTitle":         "Espace disque faible",
+		"lowDiskSpaceMessage":       "Il ne reste que %s sur le disque de téléchargement : %d téléchargement(s) en pause jusqu'à ce que de l'espace soit libéré.",
+		"diskSpaceRecoveredTitle":   "Espace disque disponible",
+		"diskSpaceRecoveredMessage": "L'espace disque est de nouveau suffisant : %d téléchargement(s) repris.",
+		"settingsGeneral":           "Général",
+		"settingsNetwork":           "Réseau",
+		"settingsSecurity":          "Sécurité",
+		"urlsToDownload":            "URLs à télécharger :",
+		"savePath":                  "Chemin de sauvegarde :",
+		"addDownloads":              "Ajouter des téléchargements",
+		"startDownload":             "Télécharger",
 	},
 }
 
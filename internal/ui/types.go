@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"gestionnaire-telechargement/internal/downloader"
 	"time"
 
 	"fyne.io/fyne/v2/widget"
@@ -23,11 +24,15 @@ type downloadDetails struct {
 
 type downloadItem struct {
 	progressBar       *widget.ProgressBar
-	status            string
+	infiniteBar       *widget.ProgressBarInfinite // Affichée à la place de progressBar si la taille est inconnue
+	nameLabel         *widget.Label
+	nameResolved      bool // Le nom affiché est celui du fichier, et non tiré de l'URL
+	status            downloader.Status
 	speedLabel        *widget.Label
 	lastUpdate        time.Time
 	lastSize          float64 // Changé de int64 à float64
 	pauseResumeButton *widget.Button
+	id                int64
 	url               string       // Ajoutez ce champ
 	card              *widget.Card // Ajoutez ce champ
 }
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"gestionnaire-telechargement/internal/database"
@@ -29,13 +30,13 @@ const (
 type UI struct {
 	downloader       *downloader.Downloader
 	window           fyne.Window
-	downloads        map[string]*downloadItem
+	downloads        map[int64]*downloadItem
 	downloadsMutex   sync.Mutex
 	downloadList     *DownloadList
 	detailsContainer *fyne.Container
 	selectedDownload *downloadDetails
 	detailsCard      *widget.Card
-	downloadSpeeds   map[string]float64
+	downloadSpeeds   map[int64]float64
 	globalSpeed      float64
 	globalSpeedLabel *widget.Label
 	lastSpeedUpdate  time.Time
@@ -52,15 +53,18 @@ func NewUI(d *downloader.Downloader, db *database.Database) *UI {
 	ui := &UI{
 		app:             a,
 		downloader:      d,
-		downloads:       make(map[string]*downloadItem),
-		downloadSpeeds:  make(map[string]float64),
+		downloads:       make(map[int64]*downloadItem),
+		downloadSpeeds:  make(map[int64]float64),
 		lastSpeedUpdate: time.Now(),
 		isMenuExpanded:  false,
 		db:              db,
 	}
-	d.SetProgressCallback(ui.updateProgress)
+	d.OnCollision = func(ctx context.Context, id int64, filePath string) (downloader.CollisionPolicy, error) {
+		return askCollisionPolicy(ctx, ui, filePath)
+	}
 	ui.downloadList = NewDownloadList(ui)
 	ui.detailsPanel = NewDetailsPanel(ui)
+	go ui.listen(d.Subscribe(downloader.DefaultEventBuffer))
 
 	ui.sideMenu = ui.createSideMenu()
 
@@ -132,10 +136,13 @@ func (u *UI) updateSideMenuSize() {
 }
 
 func (u *UI) Start() {
+	// Charger les paramètres depuis la base de données
+	u.loadSettings()
+
 	u.app.Settings().SetTheme(&myTheme{})
 	u.window = u.app.NewWindow(T("windowTitle"))
 
-	u.downloads = make(map[string]*downloadItem)
+	u.downloads = make(map[int64]*downloadItem)
 	u.downloadList = NewDownloadList(u)
 
 	u.loadExistingDownloads()
@@ -150,7 +157,7 @@ func (u *UI) Start() {
 
 	u.isMenuExpanded = true
 
-	u.globalSpeedLabel = widget.NewLabel(fmt.Sprintf(T("globalSpeed"), "0 B/s"))
+	u.globalSpeedLabel = widget.NewLabel(u.globalSpeedText(0))
 
 	mainContent := container.NewVSplit(
 		container.NewPadded(container.NewVScroll(u.downloadList.container)),
@@ -194,7 +201,16 @@ func (u *UI) Start() {
 
 	u.downloadList.Initialize()
 
-	// Charger les paramètres depuis la base de données
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		for range ticker.C {
+			u.updateDynamicElements()
+		}
+	}()
+}
+
+// loadSettings applique les paramètres enregistrés avant l'affichage de la fenêtre
+func (u *UI) loadSettings() {
 	lang, err := u.db.GetSetting("language")
 	if err != nil {
 		log.Printf("Erreur lors du chargement de la langue : %v", err)
@@ -209,12 +225,240 @@ func (u *UI) Start() {
 		u.downloader.DownloadDir = downloadDir
 	}
 
-	go func() {
-		ticker := time.NewTicker(100 * time.Millisecond)
-		for range ticker.C {
-			u.updateDynamicElements()
+	maxChunks, err := u.db.GetSetting("max_chunks")
+	if err != nil {
+		log.Printf("Erreur lors du chargement du nombre de chunks : %v", err)
+	} else if n, err := strconv.Atoi(maxChunks); err == nil && n > 0 {
+		u.downloader.MaxChunks = n
+	}
+
+	maxConcurrent, err := u.db.GetSetting("max_concurrent")
+	if err != nil {
+		log.Printf("Erreur lors du chargement du nombre de téléchargements simultanés : %v", err)
+	} else if n, err := strconv.Atoi(maxConcurrent); err == nil && n > 0 {
+		u.downloader.SetMaxConcurrent(n)
+	}
+
+	collisionPolicy, err := u.db.GetSetting("collision_policy")
+	if err != nil {
+		log.Printf("Erreur lors du chargement de la politique de collision : %v", err)
+	} else if collisionPolicy != "" {
+		u.downloader.CollisionPolicy = downloader.ParseCollisionPolicy(collisionPolicy)
+	}
+
+	speedLimit, err := u.db.GetSetting("global_speed_limit")
+	if err != nil {
+		log.Printf("Erreur lors du chargement de la limite de débit : %v", err)
+	} else if limit, err := strconv.ParseInt(speedLimit, 10, 64); err == nil && limit >= 0 {
+		u.downloader.SetGlobalLimit(limit)
+	}
+
+	minFreeSpace, err := u.db.GetSetting("min_free_space")
+	if err != nil {
+		log.Printf("Erreur lors du chargement du seuil d'espace disque : %v", err)
+	} else if n, err := strconv.ParseInt(minFreeSpace, 10, 64); err == nil && n >= 0 {
+		u.downloader.SetMinFreeSpace(n)
+	}
+
+	u.loadRetryPolicy()
+	u.loadHostLimits()
+	u.loadHostCredentials()
+	u.loadProxy()
+	u.loadTLSSettings()
+}
+
+// Paramètres de la configuration TLS, dans l'ordre attendu par downloader.ParseTLSSettings
+var tlsSettingKeys = []string{"tls_ca_files", "tls_client_certs", "tls_pins", "tls_insecure_hosts"}
+
+// applyTLSSettings analyse les listes saisies et les transmet au downloader
+func (u *UI) applyTLSSettings(values []string) error {
+	settings, err := downloader.ParseTLSSettings(values[0], values[1], values[2], values[3])
+	if err != nil {
+		return err
+	}
+	return u.downloader.SetTLSSettings(settings)
+}
+
+// loadTLSSettings applique la configuration TLS enregistrée ; invalide, elle est
+// ignorée au profit des autorités du système
+func (u *UI) loadTLSSettings() {
+	values := make([]string, len(tlsSettingKeys))
+	for i, key := range tlsSettingKeys {
+		value, err := u.db.GetSetting(key)
+		if err != nil {
+			log.Printf("Erreur lors de la lecture du paramètre %s : %v", key, err)
 		}
-	}()
+		values[i] = value
+	}
+	if err := u.applyTLSSettings(values); err != nil {
+		log.Printf("Configuration TLS ignorée : %v", err)
+	}
+}
+
+// loadProxy applique le proxy enregistré : vide pour celui de l'environnement,
+// downloader.ProxyDirect pour aucun ; une configuration invalide est ignorée
+func (u *UI) loadProxy() {
+	setting := func(key string) string {
+		value, err := u.db.GetSetting(key)
+		if err != nil {
+			log.Printf("Erreur lors de la lecture du paramètre %s : %v", key, err)
+		}
+		return strings.TrimSpace(value)
+	}
+
+	proxyText := setting("proxy_url")
+	global, err := downloader.ParseProxyURL(proxyText)
+	if proxyText != "" && err != nil {
+		log.Printf("Proxy ignoré : %v", err)
+		return
+	}
+	if global != nil {
+		if username := setting("proxy_username"); username != "" {
+			password, _ := u.db.GetSetting("proxy_password")
+			global.User = url.UserPassword(username, password)
+		}
+	}
+
+	rules, err := downloader.ParseProxyRules(setting("proxy_rules"))
+	if err != nil {
+		log.Printf("Règles de proxy ignorées : %v", err)
+	}
+
+	u.downloader.SetProxy(global, proxyText == "", rules)
+}
+
+// loadHostCredentials transmet au downloader les identifiants enregistrés par hôte
+func (u *UI) loadHostCredentials() {
+	entries, err := u.db.GetHostCredentials()
+	if err != nil {
+		log.Printf("Erreur lors du chargement des identifiants par hôte : %v", err)
+		return
+	}
+	u.downloader.SetHostCredentials(entries)
+}
+
+// saveHostCredentials enregistre creds pour l'hôte de chacune des URLs saisies
+func (u *UI) saveHostCredentials(urlsText string, creds downloader.Credentials) {
+	for _, line := range strings.Split(urlsText, "\n") {
+		parsed, err := url.Parse(strings.TrimSpace(line))
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		entry := downloader.HostCredentials{Pattern: parsed.Hostname(), Credentials: creds}
+		if err := u.db.SaveHostCredentials(entry); err != nil {
+			log.Printf("Erreur lors de l'enregistrement des identifiants de %s : %v", parsed.Hostname(), err)
+			u.showError(T("errorTitle"), T("errorSavingSettings"))
+		}
+	}
+	u.loadHostCredentials()
+}
+
+// createStoredCredentialsEditor liste les hôtes dont les identifiants sont
+// enregistrés et permet de les oublier ; les secrets ne sont jamais affichés
+func (u *UI) createStoredCredentialsEditor() fyne.CanvasObject {
+	hostSelect := widget.NewSelect(nil, nil)
+	refresh := func() {
+		entries, err := u.db.GetHostCredentials()
+		if err != nil {
+			log.Printf("Erreur lors du chargement des identifiants par hôte : %v", err)
+		}
+		patterns := make([]string, len(entries))
+		for i, entry := range entries {
+			patterns[i] = entry.Pattern
+		}
+		hostSelect.Options = patterns
+		hostSelect.ClearSelected()
+		hostSelect.PlaceHolder = T("noStoredCredentials")
+		if len(patterns) > 0 {
+			hostSelect.PlaceHolder = T("selectHost")
+		}
+		hostSelect.Refresh()
+	}
+	refresh()
+
+	forgetButton := widget.NewButton(T("forgetCredentials"), func() {
+		if hostSelect.Selected == "" {
+			return
+		}
+		if err := u.db.DeleteHostCredentials(hostSelect.Selected); err != nil {
+			log.Printf("Erreur lors de la suppression des identifiants de %s : %v", hostSelect.Selected, err)
+			u.showError(T("errorTitle"), T("errorSavingSettings"))
+			return
+		}
+		u.loadHostCredentials()
+		refresh()
+	})
+
+	return container.NewBorder(nil, nil, nil, forgetButton, hostSelect)
+}
+
+// loadHostLimits applique les limites par hôte enregistrées ; les valeurs
+// absentes ou invalides conservent la valeur par défaut
+func (u *UI) loadHostLimits() {
+	def := downloader.DefaultHostLimit()
+
+	if value, err := u.db.GetSetting("host_max_downloads"); err == nil {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			def.MaxDownloads = n
+		}
+	}
+	if value, err := u.db.GetSetting("host_max_connections"); err == nil {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			def.MaxConnections = n
+		}
+	}
+
+	var overrides []downloader.HostLimit
+	if value, err := u.db.GetSetting("host_limit_overrides"); err == nil {
+		if overrides, err = downloader.ParseHostLimits(value); err != nil {
+			log.Printf("Règles par hôte ignorées : %v", err)
+		}
+	}
+
+	u.downloader.SetHostLimits(def, overrides)
+}
+
+// parseSpeedLimit convertit une limite saisie en Ko/s en octets par seconde ; 0 ou vide signifie illimité
+func parseSpeedLimit(text string) (int64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+
+	kilobytes, err := strconv.ParseFloat(text, 64)
+	if err != nil || kilobytes < 0 {
+		return 0, fmt.Errorf("limite de débit invalide : %s", text)
+	}
+	return int64(kilobytes * 1024), nil
+}
+
+// loadRetryPolicy applique la politique de reprise enregistrée ; les valeurs
+// absentes ou invalides conservent la valeur par défaut
+func (u *UI) loadRetryPolicy() {
+	policy := downloader.DefaultRetryPolicy()
+
+	if value, err := u.db.GetSetting("retry_max_attempts"); err == nil {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			policy.MaxAttempts = n
+		}
+	}
+	if value, err := u.db.GetSetting("retry_base_delay"); err == nil {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			policy.BaseDelay = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	if value, err := u.db.GetSetting("retry_max_delay"); err == nil {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			policy.MaxDelay = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	if value, err := u.db.GetSetting("retry_jitter"); err == nil {
+		if percent, err := strconv.ParseFloat(value, 64); err == nil && percent >= 0 && percent <= 100 {
+			policy.Jitter = percent / 100
+		}
+	}
+
+	u.downloader.SetRetryPolicy(policy)
 }
 
 func (u *UI) updateDynamicElements() {
@@ -256,40 +500,60 @@ func (u *UI) loadExistingDownloads() {
 	}
 
 	for _, download := range downloads {
-		u.downloadList.addDownloadProgressToList(download.URL, download.Status)
+		u.downloadList.addDownloadProgressToList(download.ID, download.URL, download.FileName, download.Status)
 	}
 }
 
-func (u *UI) updateDownloadStatus(url, status string) {
+func (u *UI) updateDownloadStatus(id int64, status downloader.Status) {
 	u.downloadsMutex.Lock()
 	defer u.downloadsMutex.Unlock()
 
-	if item, exists := u.downloads[url]; exists {
-		item.status = status
-		if status == "completed" {
+	if item, exists := u.downloads[id]; exists && item.setStatus(status) {
+		if status == downloader.StatusCompleted {
 			item.progressBar.SetValue(1)
 		}
 	}
 }
 
-func (u *UI) updateProgress(url string, progress float64) {
-	u.downloadList.updateProgress(url, progress)
+// listen applique à l'interface les événements du downloader, jusqu'à son arrêt
+func (u *UI) listen(sub *downloader.Subscription) {
+	for event := range sub.Events() {
+		switch event.Type {
+		case downloader.EventProgress:
+			u.updateProgress(event.ID, event.Progress(), event.Downloaded)
+		case downloader.EventCompleted:
+			u.updateProgress(event.ID, event.Progress(), event.Downloaded)
+			u.downloadList.updateDownloadStatus(event.ID, downloader.StatusCompleted)
+		case downloader.EventLowDiskSpace:
+			u.onLowDiskSpace(event.Available, event.IDs)
+		case downloader.EventDiskSpaceRecovered:
+			u.onDiskSpaceRecovered(event.Available, event.IDs)
+		default:
+			if status, ok := event.Status(); ok {
+				u.downloadList.updateDownloadStatus(event.ID, status)
+			}
+		}
+	}
+}
+
+func (u *UI) updateProgress(id int64, progress float64, downloaded int64) {
+	u.downloadList.updateProgress(id, progress, downloaded)
 
-	if u.selectedDownload != nil && u.selectedDownload.URL == url {
+	if u.detailsPanel.selectedDownload != nil && u.detailsPanel.selectedDownload.ID == id {
 		u.detailsPanel.updateProgress()
 	}
 }
 
-func (u *UI) updatePauseResumeButton(url string) {
-	if item, exists := u.downloads[url]; exists {
+func (u *UI) updatePauseResumeButton(id int64) {
+	if item, exists := u.downloads[id]; exists {
 		switch item.status {
-		case "paused":
+		case downloader.StatusPaused:
 			item.pauseResumeButton.SetIcon(theme.MediaPlayIcon())
 			item.pauseResumeButton.Show()
-		case "downloading", "pending":
+		case downloader.StatusDownloading, downloader.StatusPending:
 			item.pauseResumeButton.SetIcon(theme.MediaPauseIcon())
 			item.pauseResumeButton.Show()
-		case "completed":
+		case downloader.StatusCompleted:
 			item.pauseResumeButton.Hide()
 		}
 	}
@@ -316,9 +580,9 @@ func (u *UI) showInfo(title, message string) {
 	dialog.ShowInformation(title, message, u.window)
 }
 
-func (u *UI) downloadMultiple(urlsText string) {
+func (u *UI) downloadMultiple(urlsText string, policy downloader.CollisionPolicy, checksum string, priority downloader.Priority, options downloader.HTTPOptions, creds downloader.Credentials) {
 	urls := strings.Split(urlsText, "\n")
-	validUrls := []string{}
+	requests := []downloader.Request{}
 
 	for _, urlStr := range urls {
 		urlStr = strings.TrimSpace(urlStr)
@@ -328,31 +592,92 @@ func (u *UI) downloadMultiple(urlsText string) {
 		_, err := url.ParseRequestURI(urlStr)
 		if err != nil {
 			u.showError(T("errorTitle"), fmt.Sprintf(T("invalidURL"), urlStr))
-		} else {
-			validUrls = append(validUrls, urlStr)
-			u.downloadList.addDownloadProgressToList(urlStr, "pending")
+			continue
 		}
+
+		// Chaque URL devient un téléchargement indépendant, même si elle est déjà présente
+		req, err := u.downloader.Add(urlStr)
+		if err != nil {
+			u.showError(T("errorTitle"), err.Error())
+			continue
+		}
+		req.CollisionPolicy = policy
+		req.Checksum = checksum
+		if checksum != "" {
+			if err := u.db.SetDownloadChecksum(req.ID, checksum); err != nil {
+				log.Printf("Erreur lors de l'enregistrement de l'empreinte attendue : %v", err)
+			}
+		}
+		req.Priority = priority
+		if priority != downloader.PriorityNormal {
+			if err := u.db.SetDownloadPriority(req.ID, priority); err != nil {
+				log.Printf("Erreur lors de l'enregistrement de la priorité : %v", err)
+			}
+		}
+		// Les options sont enregistrées pour que les reprises les réutilisent
+		req.Options = options
+		if err := u.db.SetDownloadOptions(req.ID, options); err != nil {
+			log.Printf("Erreur lors de l'enregistrement des options HTTP : %v", err)
+		}
+		// Les identifiants saisis priment sur ceux de l'URL, retirés par Add
+		if !creds.IsZero() {
+			req.Credentials = creds
+		}
+		if !req.Credentials.IsZero() {
+			if err := u.db.SetDownloadCredentials(req.ID, req.Credentials); err != nil {
+				log.Printf("Erreur lors de l'enregistrement des identifiants du téléchargement %d : %v", req.ID, err)
+			}
+		}
+		requests = append(requests, req)
+		u.downloadList.addDownloadProgressToList(req.ID, req.URL, "", downloader.StatusPending)
 	}
 
-	if len(validUrls) == 0 {
+	if len(requests) == 0 {
 		u.showError(T("errorTitle"), T("noValidURL"))
 		return
 	}
 
-	errors := u.downloader.DownloadMultiple(validUrls)
+	results := u.downloader.DownloadMultiple(requests)
 
+	// Les statuts affichés suivent les événements du downloader ; seules les
+	// erreurs sont signalées ici
 	successCount := 0
-	for i, err := range errors {
+	for _, err := range results {
 		if err == nil {
 			successCount++
-			u.downloadList.updateDownloadStatus(validUrls[i], "completed")
+		} else if downloader.IsInterrupted(err) {
+			// Annulé ou supprimé par l'utilisateur : ce n'est pas un échec
+			continue
+		} else if errors.Is(err, downloader.ErrInsufficientSpace) {
+			u.showError(T("downloadErrorTitle"), spaceErrorMessage(err))
 		} else {
 			u.showError(T("downloadErrorTitle"), err.Error())
-			u.downloadList.updateDownloadStatus(validUrls[i], "failed")
 		}
 	}
 
-	u.showInfo(T("downloadsCompleted"), fmt.Sprintf(T("downloadsCompletedMessage"), successCount, len(validUrls)))
+	u.showInfo(T("downloadsCompleted"), fmt.Sprintf(T("downloadsCompletedMessage"), successCount, len(requests)))
+}
+
+// spaceErrorMessage traduit un manque d'espace disque pour l'utilisateur
+func spaceErrorMessage(err error) string {
+	var spaceErr *downloader.SpaceError
+	if !errors.As(err, &spaceErr) {
+		return err.Error()
+	}
+	if spaceErr.Required > 0 {
+		return fmt.Sprintf(T("insufficientSpaceDetails"), spaceErr.Dir, formatSize(spaceErr.Required), formatSize(spaceErr.Available))
+	}
+	return fmt.Sprintf(T("insufficientSpace"), spaceErr.Dir)
+}
+
+// onLowDiskSpace signale les téléchargements mis en pause faute d'espace disque
+func (u *UI) onLowDiskSpace(available int64, paused []int64) {
+	u.showError(T("lowDiskSpaceTitle"), fmt.Sprintf(T("lowDiskSpaceMessage"), formatSize(available), len(paused)))
+}
+
+// onDiskSpaceRecovered signale les téléchargements repris une fois l'espace libéré
+func (u *UI) onDiskSpaceRecovered(available int64, resumed []int64) {
+	u.showInfo(T("diskSpaceRecoveredTitle"), fmt.Sprintf(T("diskSpaceRecoveredMessage"), len(resumed)))
 }
 
 func (u *UI) updateGlobalSpeed() {
@@ -364,12 +689,20 @@ func (u *UI) updateGlobalSpeed() {
 		}
 		u.globalSpeed = totalSpeed
 		if u.globalSpeedLabel != nil {
-			u.globalSpeedLabel.SetText(fmt.Sprintf(T("globalSpeed"), formatSpeed(totalSpeed)))
+			u.globalSpeedLabel.SetText(u.globalSpeedText(totalSpeed))
 		}
 		u.lastSpeedUpdate = now
 	}
 }
 
+// globalSpeedText retourne le texte de la vitesse globale, avec la limite active s'il y en a une
+func (u *UI) globalSpeedText(speed float64) string {
+	if limit := u.downloader.GlobalLimit(); limit > 0 {
+		return fmt.Sprintf(T("globalSpeedLimited"), formatSpeed(speed), formatSpeed(float64(limit)))
+	}
+	return fmt.Sprintf(T("globalSpeed"), formatSpeed(speed))
+}
+
 func (u *UI) filterDownloads(searchTerm, filter string) {
 	u.downloadList.filterDownloads(searchTerm, filter)
 }
@@ -403,56 +736,277 @@ func (u *UI) showSettingsDialog() {
 	chunksEntry := widget.NewEntry()
 	chunksEntry.SetText(fmt.Sprintf("%d", u.downloader.MaxChunks))
 
-	content := container.NewVBox(
-		widget.NewLabel(T("language")),
-		languageSelect,
-		widget.NewLabel(T("destinationFolder")),
-		container.NewBorder(nil, nil, nil, destinationButton, destinationEntry),
-		widget.NewLabel(T("numberOfChunks")),
-		chunksEntry,
+	concurrentEntry := widget.NewEntry()
+	concurrentEntry.SetText(strconv.Itoa(u.downloader.MaxConcurrent))
+
+	collisionSelect := widget.NewSelect(collisionPolicyLabels(), nil)
+	collisionSelect.SetSelected(collisionPolicyLabel(u.downloader.CollisionPolicy))
+
+	speedLimitEntry := widget.NewEntry()
+	speedLimitEntry.SetText(strconv.FormatInt(u.downloader.GlobalLimit()/1024, 10))
+
+	hostLimit, hostOverrides := u.downloader.HostLimits()
+	hostDownloadsEntry := widget.NewEntry()
+	hostDownloadsEntry.SetText(strconv.Itoa(hostLimit.MaxDownloads))
+	hostConnectionsEntry := widget.NewEntry()
+	hostConnectionsEntry.SetText(strconv.Itoa(hostLimit.MaxConnections))
+	hostOverridesEntry := widget.NewMultiLineEntry()
+	hostOverridesEntry.SetPlaceHolder("*.example.org 1 2")
+	hostOverridesEntry.SetText(downloader.FormatHostLimits(hostOverrides))
+
+	minFreeSpaceEntry := widget.NewEntry()
+	minFreeSpaceEntry.SetText(strconv.FormatInt(u.downloader.MinFreeSpace()>>20, 10))
+
+	// Le proxy est relu depuis les paramètres : le downloader n'en garde que la forme analysée
+	proxySettings := map[string]*widget.Entry{
+		"proxy_url":      widget.NewEntry(),
+		"proxy_username": widget.NewEntry(),
+		"proxy_password": widget.NewPasswordEntry(),
+		"proxy_rules":    widget.NewMultiLineEntry(),
+	}
+	for key, entry := range proxySettings {
+		if value, err := u.db.GetSetting(key); err == nil {
+			entry.SetText(value)
+		}
+	}
+	proxySettings["proxy_url"].SetPlaceHolder(T("proxyPlaceholder"))
+	proxySettings["proxy_rules"].SetPlaceHolder("*.example.org socks5://127.0.0.1:1080\nintranet.local direct")
+
+	tlsEntries := make([]*widget.Entry, len(tlsSettingKeys))
+	for i, key := range tlsSettingKeys {
+		tlsEntries[i] = widget.NewMultiLineEntry()
+		if value, err := u.db.GetSetting(key); err == nil {
+			tlsEntries[i].SetText(value)
+		}
+	}
+	tlsEntries[0].SetPlaceHolder("/etc/ssl/private-ca.pem")
+	tlsEntries[1].SetPlaceHolder("mirror.example.org client.pem client-key.pem")
+	tlsEntries[2].SetPlaceHolder("mirror.example.org sha256//base64=")
+	tlsEntries[3].SetPlaceHolder("*.test.local")
+
+	retryPolicy := u.downloader.RetryPolicy()
+	retryAttemptsEntry := widget.NewEntry()
+	retryAttemptsEntry.SetText(strconv.Itoa(retryPolicy.MaxAttempts))
+	retryBaseEntry := widget.NewEntry()
+	retryBaseEntry.SetText(strconv.FormatFloat(retryPolicy.BaseDelay.Seconds(), 'f', -1, 64))
+	retryMaxEntry := widget.NewEntry()
+	retryMaxEntry.SetText(strconv.FormatFloat(retryPolicy.MaxDelay.Seconds(), 'f', -1, 64))
+	retryJitterEntry := widget.NewEntry()
+	retryJitterEntry.SetText(strconv.FormatFloat(retryPolicy.Jitter*100, 'f', -1, 64))
+
+	// Chaque onglet défile séparément : la fenêtre reste utilisable quelle que soit sa taille
+	tab := func(title string, objects ...fyne.CanvasObject) *container.TabItem {
+		return container.NewTabItem(title, container.NewVScroll(container.NewVBox(objects...)))
+	}
+	content := container.NewAppTabs(
+		tab(T("settingsGeneral"),
+			widget.NewLabel(T("language")),
+			languageSelect,
+			widget.NewLabel(T("destinationFolder")),
+			container.NewBorder(nil, nil, nil, destinationButton, destinationEntry),
+			widget.NewLabel(T("collisionPolicy")),
+			collisionSelect,
+			widget.NewLabel(T("minFreeSpace")),
+			minFreeSpaceEntry,
+		),
+		tab(T("settingsNetwork"),
+			widget.NewLabel(T("numberOfChunks")),
+			chunksEntry,
+			widget.NewLabel(T("maxConcurrent")),
+			concurrentEntry,
+			widget.NewLabel(T("speedLimitGlobal")),
+			speedLimitEntry,
+			widget.NewLabel(T("hostMaxDownloads")),
+			hostDownloadsEntry,
+			widget.NewLabel(T("hostMaxConnections")),
+			hostConnectionsEntry,
+			widget.NewLabel(T("hostLimitOverrides")),
+			hostOverridesEntry,
+			widget.NewLabel(T("proxy")),
+			proxySettings["proxy_url"],
+			widget.NewLabel(T("proxyUsername")),
+			proxySettings["proxy_username"],
+			widget.NewLabel(T("proxyPassword")),
+			proxySettings["proxy_password"],
+			widget.NewLabel(T("proxyRules")),
+			proxySettings["proxy_rules"],
+			widget.NewLabel(T("retryMaxAttempts")),
+			retryAttemptsEntry,
+			widget.NewLabel(T("retryBaseDelay")),
+			retryBaseEntry,
+			widget.NewLabel(T("retryMaxDelay")),
+			retryMaxEntry,
+			widget.NewLabel(T("retryJitter")),
+			retryJitterEntry,
+		),
+		tab(T("settingsSecurity"),
+			widget.NewLabel(T("storedCredentials")),
+			u.createStoredCredentialsEditor(),
+			widget.NewLabel(T("tlsCAFiles")),
+			tlsEntries[0],
+			widget.NewLabel(T("tlsClientCerts")),
+			tlsEntries[1],
+			widget.NewLabel(T("tlsPins")),
+			tlsEntries[2],
+			widget.NewLabel(T("tlsInsecureHosts")),
+			tlsEntries[3],
+		),
 	)
 
-	dialog.ShowCustomConfirm(T("settings"), T("save"), T("cancel"), content, func(save bool) {
-		if save {
-			u.downloader.DownloadDir = destinationEntry.Text
-			err := u.db.SetSetting("download_dir", u.downloader.DownloadDir)
-			if err != nil {
-				log.Printf("Erreur lors de l'enregistrement du dossier de téléchargement : %v", err)
-				u.showError(T("errorTitle"), T("errorSavingSettings"))
-				return
-			}
+	settingsDialog := dialog.NewCustomConfirm(T("settings"), T("save"), T("cancel"), content, func(save bool) {
+		if !save {
+			return
+		}
 
-			maxChunks, err := strconv.Atoi(chunksEntry.Text)
-			if err == nil && maxChunks > 0 {
-				u.downloader.MaxChunks = maxChunks
-			}
+		// Tous les champs sont vérifiés avant d'enregistrer quoi que ce soit
+		invalid := func(label, text string) {
+			u.showError(T("errorTitle"), fmt.Sprintf(T("invalidSetting"), T(label), strings.TrimSpace(text)))
+		}
 
-			var langCode string
-			switch languageSelect.Selected {
-			case T("french"):
-				langCode = "fr"
-			default:
-				langCode = "en"
-			}
-			err = u.db.SetSetting("language", langCode)
-			if err != nil {
-				log.Printf("Erreur lors de l'enregistrement de la langue : %v", err)
-				u.showError(T("errorTitle"), T("errorSavingSettings"))
+		maxChunks, err := strconv.Atoi(strings.TrimSpace(chunksEntry.Text))
+		if err != nil || maxChunks <= 0 {
+			invalid("numberOfChunks", chunksEntry.Text)
+			return
+		}
+		maxConcurrent, err := strconv.Atoi(strings.TrimSpace(concurrentEntry.Text))
+		if err != nil || maxConcurrent <= 0 {
+			invalid("maxConcurrent", concurrentEntry.Text)
+			return
+		}
+		policy := collisionPolicyFromLabel(collisionSelect.Selected)
+		if policy == "" {
+			policy = u.downloader.CollisionPolicy
+		}
+		speedLimit, err := parseSpeedLimit(speedLimitEntry.Text)
+		if err != nil {
+			invalid("speedLimitGlobal", speedLimitEntry.Text)
+			return
+		}
+
+		if n, err := strconv.Atoi(strings.TrimSpace(hostDownloadsEntry.Text)); err != nil || n < 0 {
+			invalid("hostMaxDownloads", hostDownloadsEntry.Text)
+			return
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(hostConnectionsEntry.Text)); err != nil || n < 0 {
+			invalid("hostMaxConnections", hostConnectionsEntry.Text)
+			return
+		}
+		if _, err := downloader.ParseHostLimits(hostOverridesEntry.Text); err != nil {
+			u.showError(T("errorTitle"), fmt.Sprintf(T("invalidHostLimits"), err))
+			return
+		}
+
+		minFreeSpace, err := strconv.ParseInt(strings.TrimSpace(minFreeSpaceEntry.Text), 10, 64)
+		if err != nil || minFreeSpace < 0 {
+			invalid("minFreeSpace", minFreeSpaceEntry.Text)
+			return
+		}
+
+		if proxyText := strings.TrimSpace(proxySettings["proxy_url"].Text); proxyText != "" {
+			if _, err := downloader.ParseProxyURL(proxyText); err != nil {
+				u.showError(T("errorTitle"), fmt.Sprintf(T("invalidProxy"), err))
 				return
 			}
+		}
+		if _, err := downloader.ParseProxyRules(proxySettings["proxy_rules"].Text); err != nil {
+			u.showError(T("errorTitle"), fmt.Sprintf(T("invalidProxy"), err))
+			return
+		}
+
+		if n, err := strconv.Atoi(strings.TrimSpace(retryAttemptsEntry.Text)); err != nil || n <= 0 {
+			invalid("retryMaxAttempts", retryAttemptsEntry.Text)
+			return
+		}
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(retryBaseEntry.Text), 64); err != nil || seconds < 0 {
+			invalid("retryBaseDelay", retryBaseEntry.Text)
+			return
+		}
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(retryMaxEntry.Text), 64); err != nil || seconds < 0 {
+			invalid("retryMaxDelay", retryMaxEntry.Text)
+			return
+		}
+		if percent, err := strconv.ParseFloat(strings.TrimSpace(retryJitterEntry.Text), 64); err != nil || percent < 0 || percent > 100 {
+			invalid("retryJitter", retryJitterEntry.Text)
+			return
+		}
+
+		// Les fichiers TLS sont chargés en dernier : la configuration n'est remplacée
+		// que si elle est valide, et restaurée si l'enregistrement échoue
+		tlsValues := make([]string, len(tlsEntries))
+		for i, entry := range tlsEntries {
+			tlsValues[i] = strings.TrimSpace(entry.Text)
+		}
+		if err := u.applyTLSSettings(tlsValues); err != nil {
+			u.showError(T("errorTitle"), fmt.Sprintf(T("invalidTLSSettings"), err))
+			return
+		}
 
-			// Appliquer immédiatement le changement de langue
-			SetLanguage(language.MustParse(langCode))
+		var langCode string
+		switch languageSelect.Selected {
+		case T("french"):
+			langCode = "fr"
+		default:
+			langCode = "en"
+		}
 
-			u.refreshUI()
-			u.showInfo(T("settingsSaved"), T("settingsSavedMessage"))
+		settings := map[string]string{
+			"download_dir":         destinationEntry.Text,
+			"max_chunks":           strconv.Itoa(maxChunks),
+			"max_concurrent":       strconv.Itoa(maxConcurrent),
+			"collision_policy":     string(policy),
+			"global_speed_limit":   strconv.FormatInt(speedLimit, 10),
+			"host_max_downloads":   strings.TrimSpace(hostDownloadsEntry.Text),
+			"host_max_connections": strings.TrimSpace(hostConnectionsEntry.Text),
+			"host_limit_overrides": strings.TrimSpace(hostOverridesEntry.Text),
+			"min_free_space":       strconv.FormatInt(minFreeSpace<<20, 10),
+			"retry_max_attempts":   strings.TrimSpace(retryAttemptsEntry.Text),
+			"retry_base_delay":     strings.TrimSpace(retryBaseEntry.Text),
+			"retry_max_delay":      strings.TrimSpace(retryMaxEntry.Text),
+			"retry_jitter":         strings.TrimSpace(retryJitterEntry.Text),
+			"language":             langCode,
+		}
+		for key, entry := range proxySettings {
+			value := entry.Text
+			if key != "proxy_password" {
+				value = strings.TrimSpace(value)
+			}
+			settings[key] = value
 		}
+		for i, key := range tlsSettingKeys {
+			settings[key] = tlsValues[i]
+		}
+		if err := u.db.SetSettings(settings); err != nil {
+			log.Printf("Erreur lors de l'enregistrement des paramètres : %v", err)
+			u.loadTLSSettings()
+			u.showError(T("errorTitle"), T("errorSavingSettings"))
+			return
+		}
+
+		u.downloader.DownloadDir = destinationEntry.Text
+		u.downloader.MaxChunks = maxChunks
+		// La nouvelle limite s'applique sans interrompre les téléchargements en cours
+		u.downloader.SetMaxConcurrent(maxConcurrent)
+		u.downloader.CollisionPolicy = policy
+		u.downloader.SetGlobalLimit(speedLimit)
+		u.globalSpeedLabel.SetText(u.globalSpeedText(u.globalSpeed))
+		u.downloader.SetMinFreeSpace(minFreeSpace << 20)
+		u.loadHostLimits()
+		u.loadProxy()
+		u.loadRetryPolicy()
+
+		// Appliquer immédiatement le changement de langue
+		SetLanguage(language.MustParse(langCode))
+
+		u.refreshUI()
+		u.showInfo(T("settingsSaved"), T("settingsSavedMessage"))
 	}, u.window)
+	settingsDialog.Resize(fyne.NewSize(600, 500))
+	settingsDialog.Show()
 }
 
 func (u *UI) refreshUI() {
 	u.window.SetTitle(T("windowTitle"))
-	u.globalSpeedLabel.SetText(fmt.Sprintf(T("globalSpeed"), formatSpeed(u.globalSpeed)))
+	u.globalSpeedLabel.SetText(u.globalSpeedText(u.globalSpeed))
 
 	u.updateFilterTexts()
 